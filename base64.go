@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/jung-kurt/gofpdf"
+	"io"
+	"os"
+)
+
+// Writer breaking output into lines of fixed length
+type lineWriter struct {
+	writer io.Writer
+	length int
+	// bytes written to current line
+	column int
+}
+
+func (lw *lineWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		if lw.column == lw.length {
+			if _, err := lw.writer.Write([]byte("\n")); err != nil {
+				return written, err
+			}
+			lw.column = 0
+		}
+		n := lw.length - lw.column
+		if n > len(data) {
+			n = len(data)
+		}
+		n, err := lw.writer.Write(data[:n])
+		written += n
+		lw.column += n
+		if err != nil {
+			return written, err
+		}
+		data = data[n:]
+	}
+	return written, nil
+}
+
+// Write pdf to stdout encoded as base64, broken into lines
+// of lineLength characters unless it is 0
+func writeBase64Document(document *gofpdf.Fpdf, entries []byte, deterministic bool, lineLength int) error {
+	data, err := documentBytes(document, entries, deterministic)
+	if err != nil {
+		return err
+	}
+	var output io.Writer = os.Stdout
+	if lineLength > 0 {
+		output = &lineWriter{writer: os.Stdout, length: lineLength}
+	}
+	encoder := base64.NewEncoder(base64.StdEncoding, output)
+	if _, err = encoder.Write(data); err != nil {
+		return err
+	}
+	if err = encoder.Close(); err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write([]byte("\n"))
+	return err
+}
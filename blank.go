@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+)
+
+// Compute standard deviation of pixel luminance in 0-255 range
+func luminanceStddev(img image.Image) float64 {
+	bounds := img.Bounds()
+	var sum, sumSq float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			sum += v
+			sumSq += v * v
+		}
+	}
+	n := float64(bounds.Dx() * bounds.Dy())
+	if n == 0 {
+		return 0
+	}
+	mean := sum / n
+	return math.Sqrt(math.Max(sumSq/n-mean*mean, 0))
+}
+
+// Check if frame should be left out as blank page.
+// Kept blank pages are only reported
+func skipBlankFrame(imagepath string, frame int, opts *Options) (bool, error) {
+	if !opts.DetectBlankPages {
+		return false, nil
+	}
+	if luminanceStddev(decodeFrame(imagepath, frame)) >= opts.BlankPageThreshold {
+		return false, nil
+	}
+	if opts.KeepBlankPages {
+		fmt.Fprintf(os.Stderr, "Blank page kept: %s\n", imagepath)
+		return false, nil
+	}
+	return true, opts.warn("skipping blank page %s", imagepath)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	chapterTitleFont = "Helvetica"
+	// distance kept between left or right aligned title and page edge in mm
+	chapterTitleMargin = 20
+)
+
+// Core font families available without font file
+var coreFontFamilies = []string{"Helvetica", "Times", "Courier"}
+
+// Horizontal alignments of chapter title as understood by gofpdf
+var chapterTitleAlignments = map[string]string{
+	"left":   "L",
+	"center": "C",
+	"right":  "R",
+}
+
+// Vertical positions of chapter title center as fraction of page height
+var chapterTitlePositions = map[string]float64{
+	"top-third":    1.0 / 3,
+	"center":       1.0 / 2,
+	"bottom-third": 2.0 / 3,
+}
+
+// Group of images placed one after another in resulting pdf
+type chapter struct {
+	name  string
+	paths []string
+}
+
+// Collect chapters of images from dirpath.
+// Images located directly in dirpath form unnamed first chapter,
+// with 'recursive' each nested directory containing images
+// becomes a chapter named by its path relative to dirpath
+func collectChapters(dirpath string, opts *Options) ([]chapter, error) {
+	if opts.RespectNomedia && hasNomedia(dirpath) {
+		return nil, fmt.Errorf("Skipping directory marked as .nomedia: %s", dirpath)
+	}
+	paths, err := lsdir(dirpath, opts.Formats, opts)
+	if err != nil {
+		return nil, err
+	}
+	if paths, err = applyDirOrderFile(dirpath, paths, opts); err != nil {
+		return nil, err
+	}
+	chapters := []chapter{{paths: paths}}
+	if opts.Recursive {
+		subchapters, err := subdirChapters(dirpath, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, subchapters...)
+	}
+	return chapters, nil
+}
+
+// Walk subdirectories of root/rel depth-first in sorted order
+func subdirChapters(root, rel string, opts *Options) ([]chapter, error) {
+	subdirs, err := lssubdirs(filepath.Join(root, rel), opts)
+	if err != nil {
+		return nil, err
+	}
+	var result []chapter
+	for _, sub := range subdirs {
+		name := filepath.Join(rel, sub)
+		// nested directories are hidden as well
+		if opts.RespectNomedia && hasNomedia(filepath.Join(root, name)) {
+			if err := opts.warn("skipping directory marked as .nomedia: %s", filepath.Join(root, name)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		paths, err := lsdir(filepath.Join(root, name), opts.Formats, opts)
+		if err != nil {
+			return nil, err
+		}
+		if paths, err = applyDirOrderFile(filepath.Join(root, name), paths, opts); err != nil {
+			return nil, err
+		}
+		if len(paths) > 0 {
+			result = append(result, chapter{name: filepath.ToSlash(name), paths: paths})
+		}
+		nested, err := subdirChapters(root, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nested...)
+	}
+	return result, nil
+}
+
+// Check if directory contains .nomedia file, which hides
+// it from Android media scanner
+func hasNomedia(dirpath string) bool {
+	_, err := os.Stat(filepath.Join(dirpath, ".nomedia"))
+	return err == nil
+}
+
+// Check number of found images against -min-image-count
+// and -max-image-count, zero maximum means no limit
+func checkImageCount(count int, opts *Options) error {
+	if count < opts.MinImageCount {
+		if count == 0 {
+			return errors.New("No suitable files in given directory.")
+		}
+		return fmt.Errorf("found %d images, at least %d expected", count, opts.MinImageCount)
+	}
+	if opts.MaxImageCount > 0 && count > opts.MaxImageCount {
+		return fmt.Errorf("found %d images, at most %d expected", count, opts.MaxImageCount)
+	}
+	return nil
+}
+
+// Start new chapter wherever numeric suffix of consecutive
+// file names jumps by more than gap, e.g. img047 followed by img100.
+// New chapters are named after their first image
+func splitByNumberGap(chapters []chapter, gap uint64) []chapter {
+	var result []chapter
+	for _, ch := range chapters {
+		current := chapter{name: ch.name}
+		for i, elem := range ch.paths {
+			if i > 0 && numberGap(ch.paths[i-1], elem) > gap {
+				result = append(result, current)
+				name := strings.TrimSuffix(filepath.Base(elem), filepath.Ext(elem))
+				if ch.name != "" {
+					name = ch.name + "/" + name
+				}
+				current = chapter{name: name}
+			}
+			current.paths = append(current.paths, elem)
+		}
+		result = append(result, current)
+	}
+	return result
+}
+
+// Get difference between numeric suffixes of two file names,
+// zero when any of them has no suffix or numbers do not increase
+func numberGap(prev, next string) uint64 {
+	a, okA := numericSuffix(prev)
+	b, okB := numericSuffix(next)
+	if !okA || !okB || b < a {
+		return 0
+	}
+	return b - a
+}
+
+// Count images in all chapters
+func countImages(chapters []chapter) int {
+	count := 0
+	for _, ch := range chapters {
+		count += len(ch.paths)
+	}
+	return count
+}
+
+// Shuffle images inside every chapter, zero seed is taken from
+// current time. Effective seed is printed so that order can be reproduced
+func shuffleChapters(chapters []chapter, seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Fprintln(os.Stderr, "random seed:", seed)
+	random := rand.New(rand.NewSource(seed))
+	for _, ch := range chapters {
+		random.Shuffle(len(ch.paths), func(i, j int) {
+			ch.paths[i], ch.paths[j] = ch.paths[j], ch.paths[i]
+		})
+	}
+}
+
+// Keep only window of images counted across all chapters, first N
+// images and then last M of them. Zero disables limit, chapters left
+// without images are dropped
+func limitImages(chapters []chapter, first, last int) ([]chapter, error) {
+	total := countImages(chapters)
+	if first > total {
+		return nil, fmt.Errorf("found %d images, can not take first %d", total, first)
+	}
+	if last > total {
+		return nil, fmt.Errorf("found %d images, can not take last %d", total, last)
+	}
+	end := total
+	if first > 0 {
+		end = first
+	}
+	start := 0
+	if last > 0 && last < end {
+		start = end - last
+	}
+	var result []chapter
+	index := 0
+	for _, ch := range chapters {
+		var paths []string
+		for _, path := range ch.paths {
+			if index >= start && index < end {
+				paths = append(paths, path)
+			}
+			index++
+		}
+		if len(paths) > 0 {
+			ch.paths = paths
+			result = append(result, ch)
+		}
+	}
+	return result, nil
+}
+
+// Substitute chapter name into separator text
+func chapterTitle(text, name string) string {
+	return strings.Replace(text, "%s", name, -1)
+}
+
+// Get titles of separator pages for all named chapters
+func chapterTitles(chapters []chapter, opts *Options) []string {
+	var titles []string
+	if opts.ChapterSeparator == "" {
+		return titles
+	}
+	for _, ch := range chapters {
+		if ch.name != "" && len(ch.paths) > 0 {
+			titles = append(titles, chapterTitle(opts.ChapterSeparator, ch.name))
+		}
+	}
+	return titles
+}
+
+// Check if family is one of core fonts, case-insensitively
+func validCoreFont(family string) bool {
+	for _, elem := range coreFontFamilies {
+		if strings.EqualFold(elem, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add page containing only chapter title in large text.
+// Title family applies to core fonts, font from file is used as is
+func addSeparatorPage(document *gofpdf.Fpdf, opts *Options, font textFont, title string) {
+	template := opts.Template
+	document.AddPageFormat("P", template)
+	family := font.family
+	if family != customFontFamily {
+		family = opts.ChapterTitleFontFamily
+	}
+	document.SetFont(family, font.style, opts.ChapterTitleFontSize)
+	_, lineH := document.GetFontSize()
+	// separator has no image below, auto color falls back to black
+	setTextColor(document, opts, nil, 0, 0, template.Wd, template.Ht)
+	centerY := template.Ht * chapterTitlePositions[opts.ChapterTitleVerticalPosition]
+	document.SetXY(chapterTitleMargin, centerY-lineH)
+	align := chapterTitleAlignments[opts.ChapterTitleAlignment] + "M"
+	document.CellFormat(template.Wd-2*chapterTitleMargin, 2*lineH, font.encode(document, title), "", 0, align, false, 0, "")
+}
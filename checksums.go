@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Read manifest in sha256sum or md5sum format, i.e. lines of
+// "HASH  FILE", binary mode "HASH *FILE" is accepted as well.
+// Relative file names are resolved against manifest directory
+func readChecksums(manifest string) (map[string]string, error) {
+	file, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid checksum line %d in %s", line, manifest)
+		}
+		sum := strings.ToLower(fields[0])
+		if _, err = hex.DecodeString(sum); err != nil || (len(sum) != 32 && len(sum) != 64) {
+			return nil, fmt.Errorf("invalid checksum line %d in %s", line, manifest)
+		}
+		name := strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(manifest), name)
+		}
+		if name, err = filepath.Abs(name); err != nil {
+			return nil, err
+		}
+		sums[name] = sum
+	}
+	return sums, scanner.Err()
+}
+
+// Compute hex digest of file, md5 for 32 digit sums, sha256 otherwise
+func fileChecksum(filename string, digits int) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var hasher hash.Hash
+	if digits == 32 {
+		hasher = md5.New()
+	} else {
+		hasher = sha256.New()
+	}
+	if _, err = io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Check all images against manifest before anything is written.
+// Images missing from manifest count as mismatching
+func verifyChecksums(chapters []chapter, manifest string) error {
+	sums, err := readChecksums(manifest)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, ch := range chapters {
+		for _, elem := range ch.paths {
+			expected, ok := sums[elem]
+			if !ok {
+				failed = append(failed, elem+": not in manifest")
+				continue
+			}
+			actual, err := fileChecksum(elem, len(expected))
+			if err != nil {
+				return err
+			}
+			if actual != expected {
+				failed = append(failed, elem+": checksum mismatch")
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("checksum verification failed for %d file(s):\n%s",
+			len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
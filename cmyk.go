@@ -0,0 +1,27 @@
+package main
+
+// Get number of color components from start of frame segment
+// of jpeg, 0 when there is none
+func jpegComponents(data []byte) int {
+	components := 0
+	walkJpegSegments(data, func(marker byte, segment []byte) {
+		// SOF0-SOF15 except DHT, JPG and DAC which share the range
+		isFrame := marker >= 0xC0 && marker <= 0xCF &&
+			marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+		if isFrame && len(segment) >= 6 && components == 0 {
+			components = int(segment[5])
+		}
+	})
+	return components
+}
+
+// Check if image is jpeg with four CMYK components. Those are decoded
+// and stored as RGB, since gofpdf embeds CMYK data assuming inverted
+// Adobe encoding, while print scanners write both variants. Go decoder
+// applies Adobe inversion itself, so decoded image is always correct
+func isCmykJpeg(imagepath string, format string) bool {
+	if format != "jpeg" {
+		return false
+	}
+	return jpegComponents(readFile(imagepath)) == 4
+}
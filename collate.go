@@ -0,0 +1,38 @@
+package main
+
+// Interleave images of both sequences as first[0], second[0], first[1], ...
+// e.g. front and back sides scanned separately. With 'reverse' second
+// sequence is taken from its end, as produced by flipping the stack.
+// Result is single chapter, surplus images of longer sequence go last
+func collateChapters(first, second []chapter, reverse bool, opts *Options) ([]chapter, error) {
+	a, b := chapterPaths(first), chapterPaths(second)
+	if reverse {
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	if len(a) != len(b) {
+		if err := opts.warn("collated sequences differ in length: %d and %d images", len(a), len(b)); err != nil {
+			return nil, err
+		}
+	}
+	paths := make([]string, 0, len(a)+len(b))
+	for i := 0; i < len(a) || i < len(b); i++ {
+		if i < len(a) {
+			paths = append(paths, a[i])
+		}
+		if i < len(b) {
+			paths = append(paths, b[i])
+		}
+	}
+	return []chapter{{paths: paths}}, nil
+}
+
+// Get paths of all chapters in order
+func chapterPaths(chapters []chapter) []string {
+	var paths []string
+	for _, ch := range chapters {
+		paths = append(paths, ch.paths...)
+	}
+	return paths
+}
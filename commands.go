@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// Version of program, set at build time with
+// -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// Subcommands by name, arguments without one are passed to convert
+var subcommands = map[string]func(args []string) error{
+	"convert":        runConvert,
+	"info":           runInfo,
+	"version":        runVersion,
+	"healthcheck":    runHealthcheck,
+	"migrate-config": migrateConfig,
+	"compare":        runCompare,
+}
+
+// Convert DIR, project file or frames of video to pdf
+func runConvert(args []string) error {
+	opts, dir, err := parseArgs(args)
+	if opts == nil || err != nil {
+		return err
+	}
+	defer opts.Temp.cleanup()
+	var saveAs string
+	// output is named after video instead of frames directory
+	nameSource := dir
+	if opts.FromVideo != "" {
+		frames, err := extractVideoFrames(opts.FromVideo, opts.FromVideoFps, opts.Temp)
+		if err != nil {
+			return err
+		}
+		dir, saveAs = frames, sourceOutFilename(opts.FromVideo, opts)
+		nameSource = strings.TrimSuffix(opts.FromVideo, filepath.Ext(opts.FromVideo))
+	} else if opts.Project != nil {
+		saveAs = sourceOutFilename(dir, opts)
+		nameSource = strings.TrimSuffix(dir, projectExt)
+	} else {
+		saveAs = getOutFilename(dir, opts)
+	}
+	chapters, err := inputChapters(dir, opts)
+	if err != nil {
+		return err
+	}
+	if opts.NameTemplate != nil && (opts.Output == "" || opts.OutputDir != "") {
+		opts.OutputNames = newOutputNameData(nameSource, countImages(chapters))
+	}
+	if opts.VerifyChecksums != "" {
+		if err := verifyChecksums(chapters, opts.VerifyChecksums); err != nil {
+			return err
+		}
+	}
+	if opts.ImageRotationReport {
+		printRotationReport(chapters)
+		return nil
+	}
+	if opts.QualityReport || opts.QualityReportFile != "" {
+		if err = qualityReport(chapters, opts); err != nil {
+			return err
+		}
+	}
+	if opts.ColorProfileOutput != "" {
+		if err = exportColorProfile(chapters, opts); err != nil {
+			return err
+		}
+	}
+	if opts.PreviewPages > 0 {
+		if err = writePreview(chapters, saveAs, opts); err != nil {
+			return err
+		}
+	}
+	if opts.Incremental {
+		if opts.Cache, err = loadImageCache(cacheFile(saveAs, opts), opts); err != nil {
+			return err
+		}
+	}
+	switch opts.OutputFormat {
+	case "docx":
+		return writeDocx(chapters, exportFilename(saveAs, "docx"), opts)
+	case "epub":
+		return writeEpub(chapters, exportFilename(saveAs, "epub"), opts)
+	}
+	switch {
+	case opts.SinglePage:
+		err = writeSinglePage(chapters, saveAs, opts)
+	case opts.OnePdfPerImage:
+		err = writeImagePdfs(chapters, saveAs, opts)
+	default:
+		err = processChapters(chapters, saveAs, opts)
+	}
+	if err != nil || opts.Cache == nil {
+		return err
+	}
+	return opts.Cache.save()
+}
+
+// Collect chapters of DIR or of project inputs,
+// ordered and split according to options
+func inputChapters(dir string, opts *Options) ([]chapter, error) {
+	var chapters []chapter
+	var err error
+	if opts.Project != nil {
+		chapters, err = opts.Project.chapters(opts)
+	} else {
+		chapters, err = collectChapters(dir, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.Collate != "" {
+		first, err := collectChapters(opts.Collate, opts)
+		if err != nil {
+			return nil, err
+		}
+		if chapters, err = collateChapters(first, chapters, opts.CollateReverse, opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.OrderFile != "" {
+		names, err := readOrderFile(opts.OrderFile)
+		if err != nil {
+			return nil, err
+		}
+		if chapters, err = applyOrderFile(chapters, names, opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.FirstN > 0 || opts.LastN > 0 {
+		if chapters, err = limitImages(chapters, opts.FirstN, opts.LastN); err != nil {
+			return nil, err
+		}
+	}
+	if opts.AutoSplitChapters {
+		chapters = splitByNumberGap(chapters, opts.ChapterGap)
+	}
+	if opts.RandomOrder {
+		shuffleChapters(chapters, opts.RandomSeed)
+	}
+	return chapters, nil
+}
+
+// Print images which would be converted with their format,
+// size and number of frames, no pdf is produced
+func runInfo(args []string) error {
+	opts, dir, err := parseArgs(args)
+	if opts == nil || err != nil {
+		return err
+	}
+	chapters, err := inputChapters(dir, opts)
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "chapter\tfilename\tformat\twidth\theight\tframes\tbytes")
+	var total int64
+	for _, ch := range chapters {
+		for _, elem := range ch.paths {
+			stat, err := os.Stat(elem)
+			if err != nil {
+				return err
+			}
+			format := opts.imageType(elem)
+			w, h := getImageSize(elem)
+			frames := 1
+			if format == "tiff" {
+				frames = tiffFrameCount(elem)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%.0f\t%.0f\t%d\t%d\n",
+				ch.name, filepath.Base(elem), format, w, h, frames, stat.Size())
+			total += stat.Size()
+		}
+	}
+	writer.Flush()
+	fmt.Printf("%d images in %d chapters, %d bytes\n", countImages(chapters), len(chapters), total)
+	return nil
+}
+
+// Print version of program
+func runVersion(args []string) error {
+	fmt.Println("imgdir2pdf", version)
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// Color marking differing pixels in diff images
+var diffColor = color.RGBA{255, 0, 0, 255}
+
+// Render pages of two pdfs and compare them pixel by pixel,
+// diff images of differing pages are written into -output DIR.
+// Flags are also accepted after file names
+func runCompare(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	flags.SetOutput(os.Stdout)
+	output := flags.String("output", "", "write diff images of differing pages into `DIR`")
+	dpi := flags.Int("dpi", 72, "render pages at `DPI`")
+	var files []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		files = append(files, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(files) != 2 {
+		flags.Usage()
+		return errors.New("compare requires two pdf files")
+	}
+	if *dpi <= 0 {
+		return errors.New("dpi must be positive")
+	}
+	if _, err := exec.LookPath("mutool"); err != nil {
+		return fmt.Errorf("compare requires mutool of mupdf in PATH: %v", err)
+	}
+	temp := &tempFiles{}
+	defer temp.cleanup()
+	var pages [2][]string
+	for i, file := range files {
+		rendered, err := renderPdfPages(file, *dpi, temp)
+		if err != nil {
+			return err
+		}
+		pages[i] = rendered
+	}
+	if *output != "" {
+		if err := os.MkdirAll(*output, 0755); err != nil {
+			return err
+		}
+	}
+	total := len(pages[0])
+	if len(pages[1]) > total {
+		total = len(pages[1])
+	}
+	differ := 0
+	for i := 0; i < total; i++ {
+		var a, b image.Image
+		if i < len(pages[0]) {
+			a = decodeImage(pages[0][i])
+		}
+		if i < len(pages[1]) {
+			b = decodeImage(pages[1][i])
+		}
+		diff, changed := diffImages(a, b)
+		if !changed {
+			continue
+		}
+		differ++
+		fmt.Printf("page %d differs\n", i+1)
+		if *output != "" {
+			if err := savePng(filepath.Join(*output, fmt.Sprintf("page-%04d.png", i+1)), diff); err != nil {
+				return err
+			}
+		}
+	}
+	summary := fmt.Sprintf("%d of %d pages differ", differ, total)
+	if differ > 0 {
+		return errors.New(summary)
+	}
+	fmt.Println(summary)
+	return nil
+}
+
+// Render every page of pdf into png with mutool, returns
+// paths of rendered pages in page order
+func renderPdfPages(file string, dpi int, temp *tempFiles) ([]string, error) {
+	workDir, err := temp.mkdir("compare")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("mutool", "draw", "-q", "-r", strconv.Itoa(dpi),
+		"-o", filepath.Join(workDir, "%06d.png"), file)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("mutool failed to render %s: %v", file, err)
+	}
+	infos, err := ioutil.ReadDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, info := range infos {
+		paths = append(paths, filepath.Join(workDir, info.Name()))
+	}
+	return paths, nil
+}
+
+// Compare images pixel by pixel, missing image differs everywhere.
+// Diff shows faded first image with differing pixels in red
+func diffImages(a, b image.Image) (*image.RGBA, bool) {
+	var bounds image.Rectangle
+	for _, img := range []image.Image{a, b} {
+		if img != nil {
+			bounds = bounds.Union(img.Bounds())
+		}
+	}
+	diff := image.NewRGBA(bounds)
+	changed := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pa, okA := pixelAt(a, x, y)
+			pb, okB := pixelAt(b, x, y)
+			if !okA || !okB || pa != pb {
+				diff.SetRGBA(x, y, diffColor)
+				changed = true
+				continue
+			}
+			gray := color.GrayModel.Convert(pa).(color.Gray).Y
+			faded := 255 - (255-gray)/3
+			diff.SetRGBA(x, y, color.RGBA{faded, faded, faded, 255})
+		}
+	}
+	return diff, changed
+}
+
+// Get pixel of image, ok is false outside of its bounds
+func pixelAt(img image.Image, x, y int) (color.RGBA, bool) {
+	if img == nil || !(image.Point{x, y}).In(img.Bounds()) {
+		return color.RGBA{}, false
+	}
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA), true
+}
+
+// Encode image as png file
+func savePng(filename string, img image.Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
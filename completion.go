@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Values offered by completion for flags with fixed set of values
+func fixedFlagValues() map[string][]string {
+	return map[string][]string{
+		"page-size":                       append(append([]string{}, pageSizeNames...), "WxH"),
+		"page-number-position":            pageNumberPositions,
+		"completion":                      {"bash"},
+		"chapter-title-font-family":       coreFontFamilies,
+		"chapter-title-alignment":         {"left", "center", "right"},
+		"chapter-title-vertical-position": {"top-third", "center", "bottom-third"},
+		"page-layout":                     {"single", "two-page", "continuous"},
+		"initial-zoom":                    {"fit"},
+		"sort":                            {"name", "natural-unicode", "xattr:"},
+		"force-type":                      {"png", "jpeg", "gif", "tiff"},
+		"output-compression-filter":       {"flate", "none"},
+		"output-format":                   {"pdf", "docx", "epub"},
+		"split-on-change":                 splitProperties,
+		"output-profile":                  outputProfileNames,
+		"tiff-compression":                tiffCompressionNames,
+		"force-orientation":               {"portrait", "landscape"},
+	}
+}
+
+// Generate bash completion script. Flags with fixed values complete
+// to them, other valued flags complete to file names
+func bashCompletion(flags *flag.FlagSet) string {
+	var names, valued []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name, "--"+f.Name)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			valued = append(valued, f.Name)
+		}
+	})
+	fixed := fixedFlagValues()
+	var cases, others []string
+	for _, name := range valued {
+		if values, ok := fixed[name]; ok {
+			cases = append(cases, fmt.Sprintf("\t-%s|--%s)\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\t\treturn\n\t\t;;",
+				name, name, strings.Join(values, " ")))
+		} else {
+			others = append(others, "-"+name, "--"+name)
+		}
+	}
+	sort.Strings(cases)
+	if len(others) > 0 {
+		cases = append(cases, fmt.Sprintf("\t%s)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n\t\treturn\n\t\t;;",
+			strings.Join(others, "|")))
+	}
+	return fmt.Sprintf(`# bash completion for imgdir2pdf
+_imgdir2pdf() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	local prev="${COMP_WORDS[COMP_CWORD-1]}"
+	case "$prev" in
+%s
+	esac
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		return
+	fi
+	COMPREPLY=($(compgen -d -- "$cur"))
+}
+complete -F _imgdir2pdf imgdir2pdf
+`, strings.Join(cases, "\n"), strings.Join(names, " "))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// Version of config format written and read by this program.
+// Version 1 is the first format, so there is nothing to migrate from yet.
+// Bump it together with adding migration from previous version
+const configVersion = 1
+
+// Name of field holding format version in config file
+const configVersionField = "Version"
+
+// Migrations upgrading raw config from version N to N+1, keyed by N,
+// empty while version 1 is the only format
+var configMigrations = map[int]func(raw map[string]interface{}){}
+
+// Read config file as raw fields and its format version.
+// Files without version field predate versioning and are version 1
+func readRawConfig(filename string) (raw map[string]interface{}, version int, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, fmt.Errorf("invalid config %s: %v", filename, err)
+	}
+	version = 1
+	if v, ok := raw[configVersionField]; ok {
+		number, isNumber := v.(float64)
+		if !isNumber || number < 1 || number != float64(int(number)) {
+			return nil, 0, fmt.Errorf("invalid config version in %s: %v", filename, v)
+		}
+		version = int(number)
+	}
+	delete(raw, configVersionField)
+	return raw, version, nil
+}
+
+// Decode raw config fields into options, unknown fields are an error
+func decodeRawConfig(raw map[string]interface{}, opts *Options) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(opts)
+}
+
+// Load options from config file of current version,
+// options missing in file keep their values
+func loadConfig(filename string, opts *Options) error {
+	raw, version, err := readRawConfig(filename)
+	if err != nil {
+		return err
+	}
+	if version != configVersion {
+		return fmt.Errorf("config %s has version %d, supported version is %d",
+			filename, version, configVersion)
+	}
+	if err = decodeRawConfig(raw, opts); err != nil {
+		return fmt.Errorf("invalid config %s: %v", filename, err)
+	}
+	return nil
+}
+
+// Get names of all fields stored in config file
+func configFields() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Options{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("json") != "-" {
+			fields[t.Field(i).Name] = true
+		}
+	}
+	return fields
+}
+
+// Upgrade raw config of given version to current one with registered
+// migrations, fields unknown to current version are dropped with warning
+func migrateRawConfig(raw map[string]interface{}, version int) error {
+	if version > configVersion {
+		return fmt.Errorf("config version %d is newer than supported %d", version, configVersion)
+	}
+	for ; version < configVersion; version++ {
+		configMigrations[version](raw)
+	}
+	known := configFields()
+	var unmapped []string
+	for name := range raw {
+		if !known[name] {
+			unmapped = append(unmapped, name)
+		}
+	}
+	sort.Strings(unmapped)
+	for _, name := range unmapped {
+		fmt.Fprintf(os.Stderr, "Warning: dropping unknown field %s\n", name)
+		delete(raw, name)
+	}
+	return decodeRawConfig(raw, &Options{})
+}
+
+// Run migrate-config subcommand checking config against current format
+// and writing it without unknown fields, stamped with current version
+func migrateConfig(args []string) error {
+	flags := flag.NewFlagSet("migrate-config", flag.ExitOnError)
+	flags.SetOutput(os.Stdout)
+	from := flags.String("from", "", "read config from `FILE`")
+	to := flags.String("to", "", "write cleaned config to `FILE`")
+	dryRun := flags.Bool("dry-run", false, "print cleaned config instead of writing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || (*to == "" && !*dryRun) {
+		flags.Usage()
+		return errors.New("both -from and -to are required")
+	}
+	raw, version, err := readRawConfig(*from)
+	if err != nil {
+		return err
+	}
+	if err = migrateRawConfig(raw, version); err != nil {
+		return err
+	}
+	raw[configVersionField] = configVersion
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Println(string(data))
+		return nil
+	}
+	return ioutil.WriteFile(*to, append(data, '\n'), 0644)
+}
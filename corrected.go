@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/image/tiff"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Quality of jpeg files written by -write-corrected-images
+const correctedJpegQuality = 95
+
+// Compressions of tiff files written by -write-corrected-images,
+// encoder does not support lzw
+var tiffCompressions = map[string]tiff.CompressionType{
+	"uncompressed": tiff.Uncompressed,
+	"deflate":      tiff.Deflate,
+}
+
+// Names of tiff compressions as displayed to user
+var tiffCompressionNames = []string{"uncompressed", "deflate"}
+
+// Check if tiff compression is supported by encoder
+func validTiffCompression(name string) error {
+	if _, ok := tiffCompressions[name]; ok {
+		return nil
+	}
+	if name == "lzw" {
+		return errors.New("tiff-compression lzw is not supported by tiff encoder, use deflate")
+	}
+	return fmt.Errorf("unknown tiff-compression %q, expected uncompressed or deflate", name)
+}
+
+// Save image as it appears on page into dir under its input name,
+// i.e. processed and rotated, in format of input. Unchanged images
+// are copied as is. Frames after first of tiff get number suffix
+func writeCorrectedImage(dir, imagepath string, frame int, img pageImage, degrees int, opts *Options) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Base(imagepath)
+	if frame > 0 {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), frame+1, ext)
+	}
+	target := filepath.Join(dir, name)
+	if img.decoded == nil && degrees == 0 && frame == 0 {
+		data := readFile(imagepath)
+		if opts.StripMetadata {
+			data = stripMetadata(data, opts.imageType(imagepath))
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	}
+	pixels := img.decoded
+	if pixels == nil {
+		pixels = decodeFrame(imagepath, frame)
+	}
+	pixels = rotateImage(pixels, degrees)
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	switch opts.imageType(imagepath) {
+	case "jpeg":
+		err = jpeg.Encode(file, pixels, &jpeg.Options{Quality: correctedJpegQuality})
+	case "gif":
+		err = gif.Encode(file, pixels, nil)
+	case "tiff":
+		compression := tiffCompressions[opts.TiffCompression]
+		err = tiff.Encode(file, pixels, &tiff.Options{Compression: compression, Predictor: compression == tiff.Deflate})
+	default:
+		err = png.Encode(file, pixels)
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("writing corrected image %s: %v", target, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	xrefPattern        = regexp.MustCompile(`xref\n0 (\d+)\n0000000000 65535 f \n`)
+	objectRefPattern   = regexp.MustCompile(`\b(\d+) 0 R\b`)
+	xobjectDictPattern = regexp.MustCompile(`/XObject <<\n((?:/I[0-9a-f]+ \d+ 0 R\n)*)>>`)
+	xobjectRefPattern  = regexp.MustCompile(`/(I[0-9a-f]+) (\d+) 0 R`)
+)
+
+// Object of pdf produced by gofpdf located by cross-reference table
+type pdfObject struct {
+	number     int
+	start, end int
+}
+
+// Image object of document with objects it refers to, e.g. soft
+// mask, which gofpdf writes right after it
+type imageGroup struct {
+	name        string
+	first, last int
+}
+
+// Renumber image objects of pdf produced by gofpdf in order of their
+// names, which are hashes of image data. With catalog sort gofpdf orders
+// images only by width, so images of equal width follow map iteration
+// order and documents differ between runs
+func sortImageObjects(data []byte) ([]byte, error) {
+	objects, xrefPos, err := pdfObjects(data)
+	if err != nil {
+		return nil, err
+	}
+	byNumber := make(map[int]pdfObject, len(objects))
+	for _, obj := range objects {
+		byNumber[obj.number] = obj
+	}
+	// resources of all pages are gofpdf object 2
+	resources, ok := byNumber[2]
+	if !ok {
+		return nil, errors.New("resource dictionary not found")
+	}
+	dict := xobjectDictPattern.FindSubmatchIndex(data[resources.start:resources.end])
+	if dict == nil {
+		return data, nil
+	}
+	var groups []imageGroup
+	for _, ref := range xobjectRefPattern.FindAllSubmatch(data[resources.start+dict[2]:resources.start+dict[3]], -1) {
+		number, _ := strconv.Atoi(string(ref[2]))
+		obj, ok := byNumber[number]
+		if !ok {
+			return nil, fmt.Errorf("image object %d not found", number)
+		}
+		group := imageGroup{name: string(ref[1]), first: number, last: number}
+		for _, child := range objectRefPattern.FindAllSubmatch(objectDict(data, obj), -1) {
+			if n, _ := strconv.Atoi(string(child[1])); n > group.last {
+				group.last = n
+			}
+		}
+		groups = append(groups, group)
+	}
+	if len(groups) < 2 {
+		return data, nil
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].first < groups[j].first })
+	// images must occupy consecutive objects written one after another
+	for i := 1; i < len(groups); i++ {
+		if groups[i].first != groups[i-1].last+1 {
+			return nil, errors.New("image objects are not consecutive")
+		}
+	}
+	blockFirst, blockLast := groups[0].first, groups[len(groups)-1].last
+	for n := blockFirst; n < blockLast; n++ {
+		if byNumber[n].end != byNumber[n+1].start {
+			return nil, errors.New("image objects are not written in order")
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	renumbered := make(map[int]int)
+	next := blockFirst
+	for _, group := range groups {
+		for n := group.first; n <= group.last; n++ {
+			renumbered[n] = next
+			next++
+		}
+	}
+	remap := func(ref []byte) []byte {
+		n, _ := strconv.Atoi(string(objectRefPattern.FindSubmatch(ref)[1]))
+		if to, ok := renumbered[n]; ok {
+			return []byte(fmt.Sprintf("%d 0 R", to))
+		}
+		return ref
+	}
+	// objects are written in original order of the file, image block
+	// is replaced by its sorted groups
+	var result bytes.Buffer
+	offsets := make(map[int]int, len(objects))
+	result.Write(data[:objects[0].start])
+	for _, obj := range objects {
+		switch {
+		case obj.number == blockFirst:
+			for _, group := range groups {
+				for n := group.first; n <= group.last; n++ {
+					image := byNumber[n]
+					offsets[renumbered[n]] = result.Len()
+					body := data[image.start:image.end]
+					dictEnd := len(objectDict(data, image))
+					header := len(strconv.Itoa(n)) + len(" 0 obj\n")
+					fmt.Fprintf(&result, "%d 0 obj\n", renumbered[n])
+					result.Write(objectRefPattern.ReplaceAllFunc(body[header:dictEnd], remap))
+					result.Write(body[dictEnd:])
+				}
+			}
+		case obj.number > blockFirst && obj.number <= blockLast:
+		case obj.number == resources.number:
+			offsets[obj.number] = result.Len()
+			body := data[obj.start:obj.end]
+			result.Write(body[:dict[2]])
+			result.Write(objectRefPattern.ReplaceAllFunc(body[dict[2]:dict[3]], remap))
+			result.Write(body[dict[3]:])
+		default:
+			offsets[obj.number] = result.Len()
+			result.Write(data[obj.start:obj.end])
+		}
+	}
+	xref := xrefPattern.FindSubmatchIndex(data[xrefPos:])
+	count, _ := strconv.Atoi(string(data[xrefPos+xref[2] : xrefPos+xref[3]]))
+	newXrefPos := result.Len()
+	result.Write(data[xrefPos : xrefPos+xref[1]])
+	for n := 1; n < count; n++ {
+		fmt.Fprintf(&result, "%010d 00000 n \n", offsets[n])
+	}
+	trailer := data[xrefPos+xref[1]+(count-1)*len("0000000000 00000 n \n"):]
+	trailer = startxrefPattern.ReplaceAll(trailer, []byte(fmt.Sprintf("startxref\n%d\n", newXrefPos)))
+	result.Write(trailer)
+	return result.Bytes(), nil
+}
+
+// Get objects of pdf in the order they are written, each one ends
+// where the next one or cross-reference table starts
+func pdfObjects(data []byte) ([]pdfObject, int, error) {
+	match := startxrefPattern.FindAllSubmatch(data, -1)
+	if len(match) == 0 {
+		return nil, 0, errors.New("startxref not found")
+	}
+	xrefPos, _ := strconv.Atoi(string(match[len(match)-1][1]))
+	if xrefPos >= len(data) || xrefPattern.FindIndex(data[xrefPos:]) == nil {
+		return nil, 0, errors.New("cross-reference table not found")
+	}
+	var objects []pdfObject
+	for i, entry := range xrefEntryPattern.FindAllSubmatch(data[xrefPos:], -1) {
+		start, _ := strconv.Atoi(string(entry[1]))
+		objects = append(objects, pdfObject{number: i + 1, start: start})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].start < objects[j].start })
+	for i := range objects {
+		objects[i].end = xrefPos
+		if i+1 < len(objects) {
+			objects[i].end = objects[i+1].start
+		}
+	}
+	return objects, xrefPos, nil
+}
+
+// Get part of object before its stream, where references are
+func objectDict(data []byte, obj pdfObject) []byte {
+	body := data[obj.start:obj.end]
+	if pos := bytes.Index(body, []byte("stream\n")); pos >= 0 {
+		return body[:pos]
+	}
+	return body
+}
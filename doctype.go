@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Classes of images told apart by -detect-document-type
+const (
+	documentPhoto = "photo"
+	documentText  = "text"
+	documentMixed = "mixed"
+)
+
+// Classify image by entropy of its luminance and share of pixels near
+// black or white. Text scans are near binary, photos use full range
+func classifyDocument(img image.Image) string {
+	values, _, _ := luminances(img)
+	if len(values) == 0 {
+		return documentMixed
+	}
+	var histogram [256]float64
+	extreme := 0
+	for _, v := range values {
+		histogram[int(v)]++
+		if v < 64 || v > 192 {
+			extreme++
+		}
+	}
+	var entropy float64
+	for _, count := range histogram {
+		if count > 0 {
+			p := count / float64(len(values))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	switch share := float64(extreme) / float64(len(values)); {
+	case share > 0.9 && entropy < 4:
+		return documentText
+	case entropy > 6:
+		return documentPhoto
+	}
+	return documentMixed
+}
+
+// Detect class of image frame, with verbose output report it on stderr
+func documentType(imagepath string, frame int, verbose bool) string {
+	class := classifyDocument(decodeFrame(imagepath, frame))
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Document type of %s: %s\n", filepath.Base(imagepath), class)
+	}
+	return class
+}
+
+// Convert image to pure black and white at threshold chosen by Otsu's method
+func binarizeImage(img image.Image) *image.Gray {
+	values, w, h := luminances(img)
+	threshold := otsuThreshold(values)
+	result := image.NewGray(image.Rect(0, 0, w, h))
+	for i, v := range values {
+		if v > threshold {
+			result.Pix[i] = 255
+		}
+	}
+	return result
+}
+
+// Find luminance best separating dark and light pixels by
+// maximizing variance between the two classes
+func otsuThreshold(values []float64) float64 {
+	var histogram [256]float64
+	var sum float64
+	for _, v := range values {
+		histogram[int(v)]++
+		sum += v
+	}
+	total := float64(len(values))
+	var best, bestVariance, darkCount, darkSum float64
+	for t := 0; t < 256; t++ {
+		darkCount += histogram[t]
+		darkSum += float64(t) * histogram[t]
+		if darkCount == 0 || darkCount == total {
+			continue
+		}
+		darkMean := darkSum / darkCount
+		lightMean := (sum - darkSum) / (total - darkCount)
+		variance := darkCount * (total - darkCount) * (darkMean - lightMean) * (darkMean - lightMean)
+		if variance > bestVariance {
+			best, bestVariance = float64(t), variance
+		}
+	}
+	return best
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"strings"
+)
+
+const (
+	// Word units: twentieths of point for page, EMU for drawings
+	twipsPerMm = 1440 / mmPerInch
+	emuPerMm   = 36000
+
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Default Extension="png" ContentType="image/png"/>
+<Default Extension="jpeg" ContentType="image/jpeg"/>
+<Default Extension="gif" ContentType="image/gif"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+	docxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+	docxDocumentStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+		`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" ` +
+		`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+		`xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"><w:body>`
+	// paragraph holding single inline picture, sectPr ends its page
+	docxPicture = `<w:p><w:pPr><w:spacing w:before="0" w:after="0" w:line="240" w:lineRule="auto"/>%[5]s</w:pPr>` +
+		`<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">` +
+		`<wp:extent cx="%[2]d" cy="%[3]d"/><wp:docPr id="%[1]d" name="Picture %[1]d"/>` +
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:pic>` +
+		`<pic:nvPicPr><pic:cNvPr id="%[1]d" name="%[4]s"/><pic:cNvPicPr/></pic:nvPicPr>` +
+		`<pic:blipFill><a:blip r:embed="rId%[1]d"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>` +
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%[2]d" cy="%[3]d"/></a:xfrm>` +
+		`<a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>` +
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>`
+	docxSection = `<w:sectPr><w:pgSz w:w="%d" w:h="%d"/>` +
+		`<w:pgMar w:top="0" w:right="0" w:bottom="0" w:left="0" w:header="0" w:footer="0" w:gutter="0"/></w:sectPr>`
+)
+
+// Get path of document in format, e.g. docx, written instead of pdf at saveAs
+func exportFilename(saveAs, format string) string {
+	return strings.TrimSuffix(saveAs, ".pdf") + "." + format
+}
+
+// Get image data stored in docx or epub, images in formats readers
+// may not display or which need rotation are converted to png
+func exportImage(imagepath string, opts *Options) (data []byte, ext string, w, h float64) {
+	format := opts.imageType(imagepath)
+	degrees := imageRotation(imagepath, opts)
+	if embeddable(format) && degrees == 0 && !opts.TrimWhitespace {
+		w, h = getImageSize(imagepath)
+		data = readFile(imagepath)
+		if opts.StripMetadata {
+			data = stripMetadata(data, format)
+		}
+		return data, format, w, h
+	}
+	img := decodeFrame(imagepath, 0)
+	if opts.TrimWhitespace {
+		img = cropImage(img, trimWhitespace(img, uint8(opts.TrimTolerance)))
+	}
+	img = rotateImage(img, degrees)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes(), "png", float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+}
+
+// Write images of all chapters into Word document, each one on
+// own page sized like pdf page would be
+func writeDocx(chapters []chapter, saveAs string, opts *Options) error {
+	if err := checkImageCount(countImages(chapters), opts); err != nil {
+		return err
+	}
+	file, err := os.Create(saveAs)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	var body, rels bytes.Buffer
+	body.WriteString(docxDocumentStart)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	paths := chapterPaths(chapters)
+	for i, elem := range paths {
+		id := i + 1
+		data, ext, imageW, imageH := exportImage(elem, opts)
+		name := fmt.Sprintf("image%d.%s", id, ext)
+		if err = writeZipFile(archive, "word/media/"+name, data); err != nil {
+			return err
+		}
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/%s"/>`, id, name)
+		pageW, pageH := opts.pageSize(imageW, imageH)
+		section := fmt.Sprintf(docxSection, int(pageW*twipsPerMm), int(pageH*twipsPerMm))
+		// last section is described by body itself
+		inline := section
+		if id == len(paths) {
+			inline = ""
+		}
+		fmt.Fprintf(&body, docxPicture, id, int(pageW*emuPerMm), int(pageH*emuPerMm), name, inline)
+		if inline == "" {
+			body.WriteString(section)
+		}
+	}
+	body.WriteString("</w:body></w:document>")
+	rels.WriteString("</Relationships>")
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(docxContentTypes)},
+		{"_rels/.rels", []byte(docxRels)},
+		{"word/document.xml", body.Bytes()},
+		{"word/_rels/document.xml.rels", rels.Bytes()},
+	}
+	for _, part := range parts {
+		if err = writeZipFile(archive, part.name, part.data); err != nil {
+			return err
+		}
+	}
+	if err = archive.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Add file to zip archive
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
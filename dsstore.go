@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+)
+
+// Position of icon in Finder window
+type iconPosition struct {
+	x, y uint32
+}
+
+// Reader of .DS_Store buddy allocator file
+type dsStore struct {
+	data []byte
+	// addresses of blocks by block number
+	blocks []uint32
+}
+
+// Read icon positions Finder saved in .DS_Store of dirpath, they
+// reflect drag and drop arrangement. Missing file gives empty map
+func readIconPositions(dirpath string) (map[string]iconPosition, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirpath, ".DS_Store"))
+	if os.IsNotExist(err) {
+		return map[string]iconPosition{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	store := &dsStore{data: data}
+	positions := make(map[string]iconPosition)
+	err = store.walkRecords(func(name, code string, value []byte) {
+		if code == "Iloc" && len(value) >= 8 {
+			positions[name] = iconPosition{
+				x: binary.BigEndian.Uint32(value[0:4]),
+				y: binary.BigEndian.Uint32(value[4:8]),
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid .DS_Store in %s: %v", dirpath, err)
+	}
+	return positions, nil
+}
+
+// Sort key placing files in reading order of their icons, row by
+// row. Files without saved position follow ordered by fallback key
+func iconSortName(positions map[string]iconPosition, filename string, fallback func(string) string) string {
+	if pos, ok := positions[filename]; ok {
+		return fmt.Sprintf("\x00%010d%010d", pos.y, pos.x)
+	}
+	return "\x01" + fallback(filename)
+}
+
+var errDsStoreTruncated = errors.New("file is truncated")
+
+// Get bytes at offset, all offsets in file are relative
+// to fourth byte, which precedes "Bud1" magic
+func (store *dsStore) slice(offset, size uint32) ([]byte, error) {
+	start := uint64(offset) + 4
+	if start+uint64(size) > uint64(len(store.data)) {
+		return nil, errDsStoreTruncated
+	}
+	return store.data[start : start+uint64(size)], nil
+}
+
+// Get contents of block by its number
+func (store *dsStore) block(number uint32) ([]byte, error) {
+	if int(number) >= len(store.blocks) {
+		return nil, errors.New("block number out of range")
+	}
+	addr := store.blocks[number]
+	return store.slice(addr&^0x1f, 1<<(addr&0x1f))
+}
+
+// Call visit for every record of B-tree holding directory entries
+func (store *dsStore) walkRecords(visit func(name, code string, value []byte)) error {
+	if len(store.data) < 36 || string(store.data[4:8]) != "Bud1" {
+		return errors.New("not a .DS_Store file")
+	}
+	rootOffset := binary.BigEndian.Uint32(store.data[8:12])
+	rootSize := binary.BigEndian.Uint32(store.data[12:16])
+	root, err := store.slice(rootOffset, rootSize)
+	if err != nil {
+		return err
+	}
+	reader := &byteReader{data: root}
+	count := reader.uint32()
+	reader.skip(4)
+	for i := uint32(0); i < count; i++ {
+		store.blocks = append(store.blocks, reader.uint32())
+	}
+	// offsets are padded to multiple of 256 entries
+	reader.skip(int((256 - count%256) % 256 * 4))
+	var master uint32
+	found := false
+	for entries := reader.uint32(); entries > 0 && reader.err == nil; entries-- {
+		name := string(reader.bytes(int(reader.byte())))
+		value := reader.uint32()
+		if name == "DSDB" {
+			master, found = value, true
+		}
+	}
+	if reader.err != nil {
+		return reader.err
+	}
+	if !found {
+		return errors.New("no DSDB directory")
+	}
+	header, err := store.block(master)
+	if err != nil {
+		return err
+	}
+	if len(header) < 4 {
+		return errDsStoreTruncated
+	}
+	return store.walkNode(binary.BigEndian.Uint32(header[0:4]), visit, 0)
+}
+
+// Visit records of node and its children in order
+func (store *dsStore) walkNode(number uint32, visit func(name, code string, value []byte), depth int) error {
+	if depth > 32 {
+		return errors.New("tree is too deep")
+	}
+	data, err := store.block(number)
+	if err != nil {
+		return err
+	}
+	reader := &byteReader{data: data}
+	rightmost := reader.uint32()
+	count := reader.uint32()
+	for i := uint32(0); i < count && reader.err == nil; i++ {
+		if rightmost != 0 {
+			if err = store.walkNode(reader.uint32(), visit, depth+1); err != nil {
+				return err
+			}
+		}
+		name, code, value := reader.record()
+		if reader.err == nil {
+			visit(name, code, value)
+		}
+	}
+	if reader.err != nil {
+		return reader.err
+	}
+	if rightmost != 0 {
+		return store.walkNode(rightmost, visit, depth+1)
+	}
+	return nil
+}
+
+// Sequential big-endian reader which remembers first failure
+type byteReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (reader *byteReader) bytes(n int) []byte {
+	if reader.err != nil || n < 0 || reader.pos+n > len(reader.data) {
+		reader.err = errDsStoreTruncated
+		return nil
+	}
+	result := reader.data[reader.pos : reader.pos+n]
+	reader.pos += n
+	return result
+}
+
+func (reader *byteReader) skip(n int) {
+	reader.bytes(n)
+}
+
+func (reader *byteReader) byte() byte {
+	if b := reader.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (reader *byteReader) uint32() uint32 {
+	if b := reader.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// Read record: UTF-16 file name, structure code, typed value
+func (reader *byteReader) record() (name, code string, value []byte) {
+	length := int(reader.uint32())
+	raw := reader.bytes(length * 2)
+	units := make([]uint16, length)
+	for i := range units {
+		if raw != nil {
+			units[i] = binary.BigEndian.Uint16(raw[2*i:])
+		}
+	}
+	name = string(utf16.Decode(units))
+	code = string(reader.bytes(4))
+	switch string(reader.bytes(4)) {
+	case "bool":
+		value = reader.bytes(1)
+	case "long", "shor", "type":
+		value = reader.bytes(4)
+	case "comp", "dutc":
+		value = reader.bytes(8)
+	case "blob":
+		value = reader.bytes(int(reader.uint32()))
+	case "ustr":
+		value = reader.bytes(int(reader.uint32()) * 2)
+	default:
+		if reader.err == nil {
+			reader.err = errors.New("unknown record type")
+		}
+	}
+	return name, code, value
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+	// page is sized by viewport in pixels of its image
+	epubPage = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>%[1]s</title><meta name="viewport" content="width=%[2]d, height=%[3]d"/>
+<style>html, body { margin: 0; padding: 0; } img { display: block; width: %[2]dpx; height: %[3]dpx; }</style></head>
+<body><img src="%[4]s" alt=""/></body>
+</html>`
+)
+
+// Page of epub and name it is listed under in table of contents
+type epubItem struct {
+	page    string
+	image   string
+	chapter string
+}
+
+// Get stable identifier of book made from its title and images
+func epubIdentifier(title string, paths []string) string {
+	sum := sha1.Sum([]byte(title + "\x00" + strings.Join(paths, "\x00")))
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Write images of all chapters into ePub 3 fixed layout book, each
+// image on own page. Starts of named chapters form table of contents
+func writeEpub(chapters []chapter, saveAs string, opts *Options) error {
+	if err := checkImageCount(countImages(chapters), opts); err != nil {
+		return err
+	}
+	title := strings.TrimSuffix(filepath.Base(saveAs), filepath.Ext(saveAs))
+	file, err := os.Create(saveAs)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	// mimetype goes first and uncompressed so that format is recognized
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+	var items []epubItem
+	for _, ch := range chapters {
+		for i, elem := range ch.paths {
+			id := len(items) + 1
+			data, ext, w, h := exportImage(elem, opts)
+			item := epubItem{page: fmt.Sprintf("page%d.xhtml", id), image: fmt.Sprintf("image%d.%s", id, ext)}
+			if i == 0 {
+				item.chapter = ch.name
+			}
+			if id == 1 && item.chapter == "" {
+				item.chapter = title
+			}
+			if err = writeZipFile(archive, "OEBPS/"+item.image, data); err != nil {
+				return err
+			}
+			page := fmt.Sprintf(epubPage, html.EscapeString(title), int(w), int(h), item.image)
+			if err = writeZipFile(archive, "OEBPS/"+item.page, []byte(page)); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+	}
+	modified := time.Now().UTC()
+	if opts.Deterministic {
+		modified = time.Unix(0, 0).UTC()
+	}
+	language := opts.Language
+	if language == "" {
+		language = "und"
+	}
+	identifier := epubIdentifier(title, chapterPaths(chapters))
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"META-INF/container.xml", []byte(epubContainer)},
+		{"OEBPS/content.opf", epubPackage(title, identifier, language, modified, items)},
+		{"OEBPS/nav.xhtml", epubNav(title, items)},
+		{"OEBPS/toc.ncx", epubNcx(title, identifier, items)},
+	}
+	for _, part := range parts {
+		if err = writeZipFile(archive, part.name, part.data); err != nil {
+			return err
+		}
+	}
+	if err = archive.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Generate package document listing all pages in reading order
+func epubPackage(title, identifier, language string, modified time.Time, items []epubItem) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+<meta property="dcterms:modified">%s</meta>
+<meta property="rendition:layout">pre-paginated</meta>
+<meta property="rendition:spread">none</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+`, identifier, html.EscapeString(title), language, modified.Format("2006-01-02T15:04:05Z"))
+	for i, item := range items {
+		mediaType := "image/" + strings.TrimPrefix(filepath.Ext(item.image), ".")
+		properties := ""
+		if i == 0 {
+			properties = ` properties="cover-image"`
+		}
+		fmt.Fprintf(&buf, "<item id=\"page%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, item.page)
+		fmt.Fprintf(&buf, "<item id=\"image%d\" href=\"%s\" media-type=\"%s\"%s/>\n", i+1, item.image, mediaType, properties)
+	}
+	buf.WriteString("</manifest>\n<spine toc=\"ncx\">\n")
+	for i := range items {
+		fmt.Fprintf(&buf, "<itemref idref=\"page%d\"/>\n", i+1)
+	}
+	buf.WriteString("</spine>\n</package>\n")
+	return buf.Bytes()
+}
+
+// Generate navigation document of epub 3
+func epubNav(title string, items []epubItem) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>%s</title></head>
+<body><nav epub:type="toc" id="toc"><ol>
+`, html.EscapeString(title))
+	for _, item := range items {
+		if item.chapter != "" {
+			fmt.Fprintf(&buf, "<li><a href=\"%s\">%s</a></li>\n", item.page, html.EscapeString(item.chapter))
+		}
+	}
+	buf.WriteString("</ol></nav></body>\n</html>\n")
+	return buf.Bytes()
+}
+
+// Generate table of contents of epub 2 for older readers
+func epubNcx(title, identifier string, items []epubItem) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="%s"/></head>
+<docTitle><text>%s</text></docTitle>
+<navMap>
+`, identifier, html.EscapeString(title))
+	order := 0
+	for _, item := range items {
+		if item.chapter != "" {
+			order++
+			fmt.Fprintf(&buf, "<navPoint id=\"nav%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/></navPoint>\n",
+				order, order, html.EscapeString(item.chapter), item.page)
+		}
+	}
+	buf.WriteString("</navMap>\n</ncx>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// Tags of EXIF and tiff directories
+const (
+	orientationTag      = 0x0112
+	imageDescriptionTag = 0x010E
+)
+
+// Call visit for every jpeg segment preceding image data
+func walkJpegSegments(data []byte, visit func(marker byte, segment []byte)) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		// image data starts at SOS, metadata precedes it
+		if data[pos+1] == 0xDA {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if end > len(data) {
+			break
+		}
+		visit(data[pos+1], data[pos+4:end])
+		pos = end
+	}
+}
+
+// Get payloads of all jpeg segments with given marker whose data
+// starts with prefix, prefix itself is stripped
+func jpegSegments(data []byte, marker byte, prefix string) [][]byte {
+	var result [][]byte
+	walkJpegSegments(data, func(segmentMarker byte, segment []byte) {
+		if segmentMarker == marker && bytes.HasPrefix(segment, []byte(prefix)) {
+			result = append(result, segment[len(prefix):])
+		}
+	})
+	return result
+}
+
+// Get EXIF payload of jpeg, which is structured as tiff data
+func jpegExif(data []byte) []byte {
+	if segments := jpegSegments(data, 0xE1, "Exif\x00\x00"); len(segments) > 0 {
+		return segments[0]
+	}
+	return nil
+}
+
+// Read EXIF orientation 1-8 of jpeg or tiff image, 0 when absent
+func exifOrientation(imagepath string) int {
+	data := readFile(imagepath)
+	if !isTiff(imagepath) {
+		data = jpegExif(data)
+	}
+	if value, ok := tiffTag(data, orientationTag); ok && value >= 1 && value <= 8 {
+		return int(value)
+	}
+	return 0
+}
+
+// Read EXIF ImageDescription of jpeg or tiff image, empty when absent
+func exifDescription(imagepath string) string {
+	data := readFile(imagepath)
+	if !isTiff(imagepath) {
+		data = jpegExif(data)
+	}
+	description, _ := tiffString(data, imageDescriptionTag)
+	return strings.TrimSpace(description)
+}
+
+// Get clockwise rotation and mirroring which display image
+// with given EXIF orientation upright
+func orientationTransform(orientation int) (degrees int, mirrored bool) {
+	switch orientation {
+	case 2:
+		return 0, true
+	case 3:
+		return 180, false
+	case 4:
+		return 180, true
+	case 5:
+		return 270, true
+	case 6:
+		return 90, false
+	case 7:
+		return 90, true
+	case 8:
+		return 270, false
+	}
+	return 0, false
+}
+
+// Print EXIF orientation of all images as table
+func printRotationReport(chapters []chapter) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "filename\texif_orientation\teffective_rotation_degrees\tmirrored")
+	for _, ch := range chapters {
+		for _, elem := range ch.paths {
+			orientation := exifOrientation(elem)
+			degrees, mirrored := orientationTransform(orientation)
+			name := filepath.Base(elem)
+			if ch.name != "" {
+				name = ch.name + "/" + name
+			}
+			shown := "none"
+			if orientation > 0 {
+				shown = fmt.Sprint(orientation)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%t\n", name, shown, degrees, mirrored)
+		}
+	}
+	writer.Flush()
+}
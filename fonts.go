@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Family name under which user supplied font is registered
+const customFontFamily = "custom"
+
+// Font used for rendering text into pdf
+type textFont struct {
+	family string
+	style  string
+	utf8   bool
+}
+
+// Register font in document according to options.
+// 'texts' are all strings which will be rendered, they decide
+// between subset and full embedding when threshold is set
+func setupFont(document *gofpdf.Fpdf, opts *Options, texts []string) textFont {
+	if opts.FontFile == "" {
+		return textFont{family: chapterTitleFont, style: "B"}
+	}
+	full := opts.EmbedFullFonts ||
+		(opts.SubsetEmbedThreshold > 0 && countGlyphs(texts) > opts.SubsetEmbedThreshold)
+	if full {
+		addFullFont(document, opts.FontFile, opts.Temp)
+		return textFont{family: customFontFamily}
+	}
+	fontBytes, err := ioutil.ReadFile(opts.FontFile)
+	if err != nil {
+		panic(err)
+	}
+	// gofpdf embeds only glyphs used in document for utf8 fonts
+	document.AddUTF8FontFromBytes(customFontFamily, "", fontBytes)
+	return textFont{family: customFontFamily, utf8: true}
+}
+
+// Get all strings which will be rendered into document
+func renderedTexts(chapters []chapter, opts *Options) []string {
+	texts := chapterTitles(chapters, opts)
+	if opts.PageNumbers || opts.SummaryPage {
+		texts = append(texts, "0123456789")
+	}
+	if opts.ChapterTocPerChapter {
+		texts = append(texts, tocTexts(chapters)...)
+	}
+	return texts
+}
+
+// Convert text to encoding expected by font
+func (font textFont) encode(document *gofpdf.Fpdf, text string) string {
+	if font.utf8 {
+		return text
+	}
+	return document.UnicodeTranslatorFromDescriptor("")(text)
+}
+
+// Count distinct characters in all texts
+func countGlyphs(texts []string) int {
+	glyphs := make(map[rune]bool)
+	for _, text := range texts {
+		for _, r := range text {
+			glyphs[r] = true
+		}
+	}
+	return len(glyphs)
+}
+
+// Embed complete TrueType font file into document.
+// Font is limited to cp1252 charset, since gofpdf supports
+// full embedding only for single byte encodings
+func addFullFont(document *gofpdf.Fpdf, fontFile string, temp *tempFiles) {
+	workDir, err := temp.mkdir("font")
+	if err != nil {
+		panic(err)
+	}
+	// makefont looks up reference cp1252.map next to encoding file
+	mapFile := filepath.Join(workDir, "cp1252.map")
+	if err = ioutil.WriteFile(mapFile, []byte(cp1252Map()), 0644); err != nil {
+		panic(err)
+	}
+	fontCopy := filepath.Join(workDir, "font"+strings.ToLower(filepath.Ext(fontFile)))
+	fontBytes, err := ioutil.ReadFile(fontFile)
+	if err != nil {
+		panic(err)
+	}
+	if err = ioutil.WriteFile(fontCopy, fontBytes, 0644); err != nil {
+		panic(err)
+	}
+	if err = gofpdf.MakeFont(fontCopy, mapFile, workDir, nil, true); err != nil {
+		panic(err)
+	}
+	jsonBytes, err := ioutil.ReadFile(filepath.Join(workDir, "font.json"))
+	if err != nil {
+		panic(err)
+	}
+	zBytes, err := ioutil.ReadFile(filepath.Join(workDir, "font.z"))
+	if err != nil {
+		panic(err)
+	}
+	document.AddFontFromBytes(customFontFamily, "", jsonBytes, zBytes)
+}
+
+// Code points of cp1252 bytes 0x80-0x9F, zero marks undefined positions
+var cp1252High = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+// Generate encoding map in makefont format, i.e. "!41 U+0041 uni0041".
+// Glyph names are irrelevant for TrueType fonts
+func cp1252Map() string {
+	var sb strings.Builder
+	for pos := 0x20; pos <= 0xFF; pos++ {
+		uv := rune(pos)
+		switch {
+		case pos == 0x7F:
+			continue
+		case pos >= 0x80 && pos <= 0x9F:
+			uv = cp1252High[pos-0x80]
+			if uv == 0 {
+				continue
+			}
+		}
+		fmt.Fprintf(&sb, "!%02X U+%04X uni%04X\n", pos, uv, uv)
+	}
+	return sb.String()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Describes how images of format are read
+type FormatHandler struct {
+	// Type passed to gofpdf for direct embedding, empty when
+	// image is decoded and stored as png
+	ImageType string
+	// Get dimensions in pixels, nil uses decoder registered for image package
+	Size func(imagepath string) (w, h float64)
+}
+
+// Handlers of supported formats by lowercase extension, detected image
+// formats are registered too, e.g. jpeg for .jpg
+var registeredFormats = map[string]FormatHandler{
+	"png":  {ImageType: "PNG"},
+	"jpg":  {ImageType: "JPG"},
+	"jpeg": {ImageType: "JPG"},
+	"jfif": {ImageType: "JPG"},
+	"gif":  {ImageType: "GIF"},
+	"tif":  {},
+	"tiff": {},
+	"dng":  {},
+}
+
+// Add support for files with extension, formats compiled in
+// with build tags register themselves, e.g. psd
+func RegisterFormat(ext string, handler FormatHandler) {
+	registeredFormats[strings.ToLower(ext)] = handler
+}
+
+// Magic bytes at start of files of format
+type imageSignature struct {
+	format string
+	magic  string
+}
+
+// Signatures of supported formats
+var imageSignatures = []imageSignature{
+	{"png", pngSignature},
+	{"jpeg", "\xFF\xD8\xFF"},
+	{"gif", "GIF8"},
+	{"tiff", "II*\x00"},
+	{"tiff", "MM\x00*"},
+}
+
+// Detect format of image from magic bytes at start of file
+func detectImageType(imagepath string) (string, error) {
+	file, err := os.Open(imagepath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	header := make([]byte, 16)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	for _, elem := range imageSignatures {
+		if strings.HasPrefix(string(header[:n]), elem.magic) {
+			return elem.format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown image format of %s", imagepath)
+}
+
+// Get format of image from its content, falling back to extension
+// for unknown content. Result is lowercase, e.g. jpeg for .jpg
+func imageType(imagepath string) string {
+	if format, err := detectImageType(imagepath); err == nil {
+		return format
+	}
+	switch ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(imagepath)), "."); ext {
+	case "jpg", "jfif":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	default:
+		return ext
+	}
+}
+
+// Get format of image, -force-type takes precedence over detection
+func (opts *Options) imageType(imagepath string) string {
+	if opts.ForceType != "" {
+		return opts.ForceType
+	}
+	return imageType(imagepath)
+}
+
+// Check if gofpdf is able to embed image of given format
+func embeddable(format string) bool {
+	return registeredFormats[format].ImageType != ""
+}
+
+// Parse comma separated list of extensions, e.g. ".jpe,.webp2",
+// into lowercase extensions without leading dot
+func parseExtensions(list string) []string {
+	var result []string
+	for _, elem := range strings.Split(list, ",") {
+		ext := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(elem)), ".")
+		if ext != "" {
+			result = append(result, ext)
+		}
+	}
+	return result
+}
+
+// Normalize format name given to -force-type, e.g. jpg to jpeg
+func parseForceType(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case "png", "jpeg", "gif", "tiff":
+		return format, nil
+	case "jpg":
+		return "jpeg", nil
+	case "tif":
+		return "tiff", nil
+	}
+	return "", fmt.Errorf("unknown force-type %q, expected png, jpeg, gif or tiff", format)
+}
+
+// Get extensions to look for, registered formats extended by 'include'
+// without ones listed in 'exclude'
+func effectiveFormats(include, exclude []string) []string {
+	var registered []string
+	for ext := range registeredFormats {
+		registered = append(registered, ext)
+	}
+	sort.Strings(registered)
+	var result []string
+	for _, ext := range append(registered, include...) {
+		if !any(ext, result, equal) && !any(ext, exclude, equal) {
+			result = append(result, ext)
+		}
+	}
+	return result
+}
+
+// Check if strings are equal
+func equal(a, b string) bool {
+	return a == b
+}
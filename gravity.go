@@ -0,0 +1,40 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Find visual center of gravity of image as fractions of its width
+// and height. Pixels are weighted by their darkness, ones within
+// tolerance from white count as background. Blank image gives its center
+func gravityCenter(img image.Image, tolerance uint8) (fx, fy float64) {
+	bounds := img.Bounds()
+	var sumX, sumY, total float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			darkness := 255 - float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			if darkness <= float64(tolerance) {
+				continue
+			}
+			sumX += darkness * (float64(x-bounds.Min.X) + 0.5)
+			sumY += darkness * (float64(y-bounds.Min.Y) + 0.5)
+			total += darkness
+		}
+	}
+	if total == 0 {
+		return 0.5, 0.5
+	}
+	return sumX / total / float64(bounds.Dx()), sumY / total / float64(bounds.Dy())
+}
+
+// Move rect of w x h so that point at fractions fx, fy of it lies in
+// center of area, rect is kept inside area
+func gravityRect(w, h, areaX, areaY, areaW, areaH, fx, fy float64) (x, y float64) {
+	x = areaX + areaW/2 - fx*w
+	y = areaY + areaH/2 - fy*h
+	x = math.Max(areaX, math.Min(x, areaX+areaW-w))
+	y = math.Max(areaY, math.Min(y, areaY+areaH-h))
+	return x, y
+}
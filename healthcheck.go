@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Verify that conversion is possible in current environment,
+// every check prints its result. Used as container probe
+func runHealthcheck(args []string) error {
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	flags.SetOutput(os.Stdout)
+	outputDir := flags.String("output-dir", ".", "check that `DIR` is writable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"decode image", checkDecode},
+		{"create pdf", checkPdf},
+		{"write to " + *outputDir, func() error { return checkWritable(*outputDir) }},
+	}
+	failed := false
+	for _, check := range checks {
+		if err := check.run(); err != nil {
+			fmt.Printf("FAIL %s: %v\n", check.name, err)
+			failed = true
+		} else {
+			fmt.Printf("ok   %s\n", check.name)
+		}
+	}
+	if failed {
+		return errors.New("healthcheck failed")
+	}
+	return nil
+}
+
+// Get small image with gradient for checks
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+	return img
+}
+
+// Encode and decode jpeg in memory
+func checkDecode() error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		return err
+	}
+	img, format, err := image.Decode(&buf)
+	if err != nil {
+		return err
+	}
+	if format != "jpeg" || img.Bounds().Dx() != 16 {
+		return fmt.Errorf("decoded %s image of unexpected size", format)
+	}
+	return nil
+}
+
+// Produce single page document with image in memory
+func checkPdf() error {
+	document := createDocument(a4Width, a4Height)
+	document.AddPage()
+	registerImage(document, "healthcheck", testImage(), 0)
+	placeImage(document, pageImage{name: "healthcheck", imageType: "PNG"}, 0, 0, a4Width, a4Width, 0)
+	if err := checkPdfState(document, "building document"); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := document.Output(&buf); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		return errors.New("output is not a pdf")
+	}
+	return nil
+}
+
+// Create and remove file in dir. Missing dir is created by conversion,
+// so then its nearest existing parent has to be writable
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !os.IsNotExist(err) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+	file, err := ioutil.TempFile(dir, ".imgdir2pdf-healthcheck")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
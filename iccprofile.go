@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Name of file written by -color-profile-output
+const iccProfileName = "icc_profile.icc"
+
+// Extract ICC profile embedded in jpeg APP2 segments or png iCCP
+// chunk, nil when image has none
+func iccProfile(imagepath string) ([]byte, error) {
+	data := readFile(imagepath)
+	switch imageType(imagepath) {
+	case "jpeg":
+		return jpegIccProfile(data)
+	case "png":
+		return pngIccProfile(data)
+	}
+	return nil, nil
+}
+
+// Join profile split over APP2 segments, each one starts
+// with its 1-based sequence number and total count
+func jpegIccProfile(data []byte) ([]byte, error) {
+	segments := jpegSegments(data, 0xE2, "ICC_PROFILE\x00")
+	if len(segments) < 1 {
+		return nil, nil
+	}
+	for _, segment := range segments {
+		if len(segment) < 2 {
+			return nil, errors.New("ICC profile segment is truncated")
+		}
+	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i][0] < segments[j][0]
+	})
+	var profile bytes.Buffer
+	for _, segment := range segments {
+		profile.Write(segment[2:])
+	}
+	return profile.Bytes(), nil
+}
+
+// Decompress profile of iCCP chunk, which holds profile name,
+// zero byte, compression method and zlib stream
+func pngIccProfile(data []byte) ([]byte, error) {
+	chunk := pngChunk(data, "iCCP")
+	if chunk == nil {
+		return nil, nil
+	}
+	nameEnd := bytes.IndexByte(chunk, 0)
+	if nameEnd < 0 || nameEnd+2 > len(chunk) {
+		return nil, errors.New("iCCP chunk is truncated")
+	}
+	reader, err := zlib.NewReader(bytes.NewReader(chunk[nameEnd+2:]))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// Write ICC profile of first image into -color-profile-output directory
+func exportColorProfile(chapters []chapter, opts *Options) error {
+	dir := opts.ColorProfileOutput
+	for _, ch := range chapters {
+		if len(ch.paths) < 1 {
+			continue
+		}
+		profile, err := iccProfile(ch.paths[0])
+		if err != nil {
+			return fmt.Errorf("reading ICC profile of %s: %v", ch.paths[0], err)
+		}
+		if profile == nil {
+			return opts.warn("no ICC profile found in %s", ch.paths[0])
+		}
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, iccProfileName), profile, 0644)
+	}
+	return nil
+}
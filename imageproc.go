@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+const (
+	mmPerInch = 25.4
+	// resolution sufficient for viewing on screen
+	screenDpi = 96
+)
+
+// Image ready to be placed on page
+type pageImage struct {
+	// name and type to pass to ImageOptions
+	name      string
+	imageType string
+	// size in pixels
+	w, h float64
+	// size of encoded image data in bytes
+	size int64
+	// processed image, nil when embedded directly from file
+	decoded image.Image
+	// ratio of processed to source pixels, zero when not resampled
+	scale float64
+}
+
+// Get size in pixels image had before it was resampled
+func (img pageImage) sourceSize() (w, h float64) {
+	if img.scale == 0 {
+		return img.w, img.h
+	}
+	return img.w / img.scale, img.h / img.scale
+}
+
+// Prepare image for embedding. Unmodified images are embedded directly
+// from file, otherwise processed copy is registered in document.
+// Frame selects image inside multi-frame tiff
+func prepareImage(document *gofpdf.Fpdf, imagepath string, frame int, opts *Options) pageImage {
+	w, h := getFrameSize(imagepath, frame)
+	// page width is fixed, so is highest useful resolution
+	maxWidth := pixelsForSize(opts.Template.Wd, screenDpi)
+	downscale := opts.OptimizeForScreen && int(w) > maxWidth
+	format := opts.imageType(imagepath)
+	direct := embeddable(format) && !isCmykJpeg(imagepath, format)
+	// text scans are stored as black and white
+	binarize := opts.DetectDocumentType && documentType(imagepath, frame, opts.Verbose) == documentText
+	if direct && !opts.TrimWhitespace && !downscale && opts.Normalize == nil && !opts.Preview && !binarize {
+		if opts.PngCompression > 0 && format == "png" {
+			name := fmt.Sprintf("%s#%d", imagepath, frame)
+			data := readFile(imagepath)
+			if opts.StripMetadata {
+				data = stripMetadata(data, format)
+			}
+			size := registerPng(document, name, data, opts.PngCompression)
+			return pageImage{name: name, imageType: "PNG", w: w, h: h, size: size}
+		}
+		if opts.StripMetadata {
+			name := fmt.Sprintf("%s#%d", imagepath, frame)
+			data := stripMetadata(readFile(imagepath), format)
+			imageType := registeredFormats[format].ImageType
+			document.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+			return pageImage{name: name, imageType: imageType, w: w, h: h, size: int64(len(data))}
+		}
+		stat, err := os.Stat(imagepath)
+		if err != nil {
+			panic(err)
+		}
+		return pageImage{name: imagepath, imageType: registeredFormats[format].ImageType, w: w, h: h, size: stat.Size()}
+	}
+	name := fmt.Sprintf("%s#%d", imagepath, frame)
+	if opts.Cache != nil {
+		if data, scale, ok := opts.Cache.lookup(imagepath, frame); ok {
+			cachedW, cachedH := pngSize(data)
+			size := registerPng(document, name, data, opts.PngCompression)
+			return pageImage{name: name, imageType: "PNG", w: cachedW, h: cachedH, size: size, scale: scale}
+		}
+	}
+	var img image.Image
+	if opts.ImageResolutionLevel != "" && format == "tiff" {
+		img = decodeReader(openTiffLevel(imagepath, opts.ResolutionLevel))
+	} else {
+		img = decodeFrame(imagepath, frame)
+	}
+	if opts.TrimWhitespace {
+		img = cropImage(img, trimWhitespace(img, uint8(opts.TrimTolerance)))
+	}
+	if binarize {
+		img = binarizeImage(img)
+	}
+	// downscaling keeps physical size with -output-dpi
+	scale := 1.0
+	if opts.OptimizeForScreen && img.Bounds().Dx() > maxWidth {
+		bounds := img.Bounds()
+		scale *= float64(maxWidth) / float64(bounds.Dx())
+		img = scaleImage(img, maxWidth, bounds.Dy()*maxWidth/bounds.Dx())
+	}
+	if size := opts.Normalize; size != nil {
+		// letterbox in orientation image has on page
+		normW, normH := size.w, size.h
+		if swapsSides(imageRotation(imagepath, opts)) {
+			normW, normH = normH, normW
+		}
+		img = letterboxImage(img, normW, normH, opts.PaddingColor)
+	}
+	if opts.Preview && img.Bounds().Dx() > previewMaxWidth {
+		bounds := img.Bounds()
+		scale *= float64(previewMaxWidth) / float64(bounds.Dx())
+		img = scaleImage(img, previewMaxWidth, bounds.Dy()*previewMaxWidth/bounds.Dx())
+	}
+	imageType := "PNG"
+	var size int64
+	if opts.Preview {
+		imageType = "JPG"
+		size = registerJpeg(document, name, img, previewJpegQuality)
+	} else {
+		data := encodePng(img)
+		if opts.Cache != nil {
+			// image is placed on page added next
+			opts.Cache.store(imagepath, frame, data, scale, document.PageNo()+1)
+		}
+		size = registerPng(document, name, data, opts.PngCompression)
+	}
+	return pageImage{
+		name:      name,
+		imageType: imageType,
+		w:         float64(img.Bounds().Dx()),
+		h:         float64(img.Bounds().Dy()),
+		size:      size,
+		decoded:   img,
+		scale:     scale,
+	}
+}
+
+// Get number of pixels covering size in mm at given dpi
+func pixelsForSize(mm, dpi float64) int {
+	return int(math.Round(mm / mmPerInch * dpi))
+}
+
+// Resample image to w x h pixels
+func scaleImage(img image.Image, w, h int) image.Image {
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(result, result.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return result
+}
+
+// Resample image to fit w x h pixels keeping its aspect ratio,
+// remaining area is filled with background
+func letterboxImage(img image.Image, w, h int, background rgb) image.Image {
+	bounds := img.Bounds()
+	scale := math.Min(float64(w)/float64(bounds.Dx()), float64(h)/float64(bounds.Dy()))
+	fitW := int(math.Round(float64(bounds.Dx()) * scale))
+	fitH := int(math.Round(float64(bounds.Dy()) * scale))
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	fill := color.RGBA{uint8(background.r), uint8(background.g), uint8(background.b), 255}
+	draw.Draw(result, result.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+	x, y := (w-fitW)/2, (h-fitH)/2
+	xdraw.CatmullRom.Scale(result, image.Rect(x, y, x+fitW, y+fitH), img, bounds, xdraw.Over, nil)
+	return result
+}
+
+// Decode image from file
+func decodeImage(imagepath string) image.Image {
+	file, err := os.Open(imagepath)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		panic(err)
+	}
+	return img
+}
+
+// Get size of frame in pixels
+func getFrameSize(imagepath string, frame int) (w, h float64) {
+	if frame == 0 {
+		return getImageSize(imagepath)
+	}
+	imgconf, _, err := image.DecodeConfig(openTiffFrame(imagepath, frame))
+	if err != nil {
+		panic(err)
+	}
+	return float64(imgconf.Width), float64(imgconf.Height)
+}
+
+// Decode frame of image
+func decodeFrame(imagepath string, frame int) image.Image {
+	if frame == 0 {
+		return decodeImage(imagepath)
+	}
+	return decodeReader(openTiffFrame(imagepath, frame))
+}
+
+// Decode image from reader
+func decodeReader(reader io.Reader) image.Image {
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		panic(err)
+	}
+	return img
+}
+
+// Register processed image in document under given name,
+// image is stored losslessly as png compressed at zlib level
+// 1-9, 0 keeps encoder default. Returns encoded size in bytes
+func registerImage(document *gofpdf.Fpdf, name string, img image.Image, level int) int64 {
+	return registerPng(document, name, encodePng(img), level)
+}
+
+// Encode image as png gofpdf is able to embed
+func encodePng(img image.Image) []byte {
+	switch img.(type) {
+	case *image.Gray, *image.RGBA, *image.NRGBA, *image.Paletted:
+	default:
+		// gofpdf does not support 16-bit png, which is
+		// what encoder produces for other image types
+		img = toRGBA(img)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Register image in document as jpeg of given quality,
+// returns encoded size in bytes
+func registerJpeg(document *gofpdf.Fpdf, name string, img image.Image, quality int) int64 {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		panic(err)
+	}
+	document.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "JPG"}, &buf)
+	return int64(buf.Len())
+}
+
+// Register png data in document, recompressed when level is 1-9.
+// Returns registered size in bytes
+func registerPng(document *gofpdf.Fpdf, name string, data []byte, level int) int64 {
+	if level > 0 {
+		var err error
+		if data, err = recompressPng(data, level); err != nil {
+			panic(err)
+		}
+	}
+	document.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(data))
+	return int64(len(data))
+}
+
+// Read whole file
+func readFile(filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Copy image into RGBA buffer
+func toRGBA(img image.Image) *image.RGBA {
+	result := image.NewRGBA(img.Bounds())
+	draw.Draw(result, result.Bounds(), img, img.Bounds().Min, draw.Src)
+	return result
+}
+
+// Get part of image inside rect
+func cropImage(img image.Image, rect image.Rectangle) image.Image {
+	type subImager interface {
+		SubImage(r image.Rectangle) image.Image
+	}
+	if sub, ok := img.(subImager); ok {
+		return sub.SubImage(rect)
+	}
+	cropped := image.NewRGBA(rect)
+	draw.Draw(cropped, rect, img, rect.Min, draw.Src)
+	return cropped
+}
+
+// Find bounding box of pixels which are not white.
+// Pixel is white when all its channels are within 'tolerance' from 255.
+// Whole image bounds are returned for blank images
+func trimWhitespace(img image.Image, tolerance uint8) image.Rectangle {
+	bounds := img.Bounds()
+	limit := uint32(255-tolerance) * 0x101
+	box := image.Rectangle{Min: bounds.Max, Max: bounds.Min}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if r >= limit && g >= limit && b >= limit {
+				continue
+			}
+			box = box.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	if box.Empty() {
+		return bounds
+	}
+	return box
+}
@@ -1,31 +1,40 @@
 package main
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/jung-kurt/gofpdf"
 	"image"
 	"io/ioutil"
+	"math"
 	"os"
-	"path"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	helpString = "\nusage: imgdir2pdf DIR\n" +
+	helpString = "\nusage: imgdir2pdf [convert] [OPTIONS] DIR\n" +
+		"       imgdir2pdf info [OPTIONS] DIR\n" +
+		"       imgdir2pdf version\n" +
+		"       imgdir2pdf healthcheck [-output-dir DIR]\n" +
+		"       imgdir2pdf migrate-config -from FILE -to FILE\n" +
+		"       imgdir2pdf compare [-output DIR] A.pdf B.pdf\n" +
 		"Convert all images in given directory to single pdf.\n" +
 		"Order is defined by sorting their names.\n" +
-		"\nSupported files: png, jpg, jpeg, gif (first frame only)\n" +
-		"Resulting PDF matches DIR's base name and is saved in DIR.\n"
+		"\nSupported files: png, jpg, jpeg, jfif, gif (first frame only),\n" +
+		"tif, tiff (first frame only unless -tiff-all-frames), dng (preview only)\n" +
+		"Resulting PDF matches DIR's base name and is saved in DIR.\n" +
+		"\nOptions:"
 	a4Width  = 210
 	a4Height = 297
 )
 
-var imageFormats = [...]string{"png", "jpg", "jpeg", "gif"}
-
 // Print program help message
 func printHelp() {
 	fmt.Println(helpString)
@@ -44,32 +53,131 @@ func any(target string, other []string, f strCheck) bool {
 }
 
 // Get list of all files with extensions from 'fileExtension' in dirpath.
-// Resulting paths are absolute
-func lsdir(dirpath string, fileExtension []string) []string {
+// Extensions are lowercase and match case-insensitively, files without
+// extension are included when their content is a known image format.
+// All files except pdf ones, which may be earlier output, hidden
+// and sidecar ones are listed when 'fileExtension' is nil.
+// Resulting paths are absolute, sorted according to opts
+func lsdir(dirpath string, fileExtension []string, opts *Options) ([]string, error) {
 	var result []string
 	files, err := ioutil.ReadDir(dirpath)
 	if err != nil {
 		panic(err)
 	}
 	for _, elem := range files {
-		curfile := elem.Name()
-		if !elem.IsDir() && any(curfile, fileExtension, strings.HasSuffix) {
+		if elem.IsDir() {
+			continue
+		}
+		curfile := strings.ToLower(elem.Name())
+		if fileExtension == nil {
+			if strings.HasSuffix(curfile, ".pdf") || isSidecarFile(elem.Name()) {
+				continue
+			}
+			// content of every file is trusted to be of forced type
+			if err = checkDecodable(filepath.Join(dirpath, elem.Name())); err != nil {
+				return nil, err
+			}
+			result = append(result, elem.Name())
+		} else if any(curfile, fileExtension, strings.HasSuffix) {
 			result = append(result, elem.Name())
+		} else if filepath.Ext(curfile) == "" {
+			if _, err = detectImageType(filepath.Join(dirpath, elem.Name())); err == nil {
+				result = append(result, elem.Name())
+			}
 		}
 	}
-	sort.Slice(
-		result,
-		func(i, j int) bool {
-			return sortName(result[i]) < sortName(result[j])
-		},
-	)
+	if err = sortNames(dirpath, result, opts); err != nil {
+		return nil, err
+	}
 	for i, elem := range result {
 		result[i], err = filepath.Abs(filepath.Join(dirpath, elem))
 		if err != nil {
 			panic(err)
 		}
 	}
-	return result
+	return result, nil
+}
+
+// Check if file is hidden or written by imgdir2pdf itself, such as
+// order, cache, project or json config files, and never an image
+func isSidecarFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(name, ".") || name == cacheFilename || name == dirOrderFile ||
+		strings.HasSuffix(lower, projectExt) || strings.HasSuffix(lower, ".json")
+}
+
+// Check that image of file can be decoded
+func checkDecodable(imagepath string) error {
+	file, err := os.Open(imagepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, _, err = image.DecodeConfig(file); err != nil {
+		return fmt.Errorf("cannot decode %s as image: %v", imagepath, err)
+	}
+	return nil
+}
+
+// Get names of all subdirectories in dirpath in sorted order
+func lssubdirs(dirpath string, opts *Options) ([]string, error) {
+	var result []string
+	files, err := ioutil.ReadDir(dirpath)
+	if err != nil {
+		panic(err)
+	}
+	for _, elem := range files {
+		if elem.IsDir() {
+			result = append(result, elem.Name())
+		}
+	}
+	if err = sortNames(dirpath, result, opts); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Sort names of files in dirpath in place using sortName keys,
+// unicodeSortName ones with -sort natural-unicode,
+// delimitedSortName ones with -natural-sort-delimiter,
+// xattrSortName ones with -sort xattr:NAME
+// or iconSortName ones with -use-ds-store
+func sortNames(dirpath string, names []string, opts *Options) error {
+	key := sortName
+	segmentKey := numericSuffixKey
+	if opts.Sort == sortNaturalUnicode {
+		key, segmentKey = unicodeSortName, unicodeNumberKey
+	}
+	if opts.NaturalSortDelimiter != "" {
+		key = func(filename string) string {
+			return delimitedSortName(filename, opts.NaturalSortDelimiter, segmentKey)
+		}
+	}
+	if opts.SortXattr != "" {
+		fallback := key
+		key = func(filename string) string {
+			return xattrSortName(dirpath, filename, opts.SortXattr, fallback)
+		}
+	}
+	if opts.UseDsStore {
+		if positions, err := readIconPositions(dirpath); err != nil {
+			if err = opts.warn("%v, sorting by name", err); err != nil {
+				return err
+			}
+		} else {
+			fallback := key
+			key = func(filename string) string {
+				return iconSortName(positions, filename, fallback)
+			}
+		}
+	}
+	sort.Slice(
+		names,
+		func(i, j int) bool {
+			return key(names[i]) < key(names[j])
+		},
+	)
+	return nil
 }
 
 // adapted from https://stackoverflow.com/questions/51359930/sorting-strings-with-numbers-in-filenames-with-golang
@@ -79,14 +187,26 @@ func lsdir(dirpath string, fileExtension []string) []string {
 func sortName(filename string) string {
 	ext := filepath.Ext(filename)
 	name := filename[:len(filename)-len(ext)]
-	// split numeric suffix
-	i := len(name) - 1
-	for ; i >= 0; i-- {
-		if '0' > name[i] || name[i] > '9' {
-			break
-		}
+	// prefix + numeric-suffix + ext
+	return numericSuffixKey(name) + ext
+}
+
+// Sort key which splits filename into segments by delimiter and
+// orders each segment by segmentKey, e.g. with "-" ch2-pg10.jpg
+// compares as ch, 2, pg, 10, .jpg
+func delimitedSortName(filename, delimiter string, segmentKey func(string) string) string {
+	ext := filepath.Ext(filename)
+	segments := strings.Split(filename[:len(filename)-len(ext)], delimiter)
+	for i, segment := range segments {
+		segments[i] = segmentKey(segment)
 	}
-	i++
+	// zero byte separator puts shorter segments first
+	return strings.Join(segments, "\x00") + ext
+}
+
+// Get name with numeric suffix replaced by its uint64 bytes
+func numericSuffixKey(name string) string {
+	i := numericSuffixStart(name)
 	// string numeric suffix to uint64 bytes
 	// empty string is zero, so integers are plus one
 	b64 := make([]byte, 64/8)
@@ -97,14 +217,40 @@ func sortName(filename string) string {
 			binary.BigEndian.PutUint64(b64, u64+1)
 		}
 	}
-	// prefix + numeric-suffix + ext
-	return name[:i] + string(b64) + ext
+	return name[:i] + string(b64)
+}
+
+// Find index where numeric suffix of name starts,
+// len(name) if there is no suffix
+func numericSuffixStart(name string) int {
+	i := len(name) - 1
+	for ; i >= 0; i-- {
+		if '0' > name[i] || name[i] > '9' {
+			break
+		}
+	}
+	return i + 1
+}
+
+// Get numeric suffix of filename without extension,
+// ok is false if there is none
+func numericSuffix(filename string) (n uint64, ok bool) {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	s64 := name[numericSuffixStart(name):]
+	if len(s64) < 1 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s64, 10, 64)
+	return n, err == nil
 }
 
 // Image Processing
 
 // Get dimenstions of given image
 func getImageSize(imagepath string) (w, h float64) {
+	if handler := registeredFormats[imageType(imagepath)]; handler.Size != nil {
+		return handler.Size(imagepath)
+	}
 	file, err := os.Open(imagepath)
 	if err != nil {
 		panic(err)
@@ -117,18 +263,206 @@ func getImageSize(imagepath string) (w, h float64) {
 	return float64(imgconf.Width), float64(imgconf.Height)
 }
 
-// Add image to pdf
-func addImagePage(document *gofpdf.Fpdf, imagepath string) {
-	imageW, imageH := getImageSize(imagepath)
-	resW, resH := optimalPageSize(a4Width, a4Height, imageW, imageH)
-	ext := strings.ToUpper(path.Ext(imagepath)[1:])
-	document.AddPageFormat("P", gofpdf.SizeType{Wd: resW, Ht: resH})
-	document.ImageOptions(imagepath, 0, 0, resW, resH, false, gofpdf.ImageOptions{ImageType: ext, ReadDpi: true}, 0, "")
+// Add image to pdf, returns size of embedded image data in bytes.
+// With -tiff-all-frames every frame of tiff becomes a page
+func addImagePage(document *gofpdf.Fpdf, imagepath string, cover bool, opts *Options, pages pageLog) (int64, error) {
+	frames := 1
+	if opts.TiffAllFrames && opts.imageType(imagepath) == "tiff" {
+		frames = tiffFrameCount(imagepath)
+	}
+	var size int64
+	for frame := 0; frame < frames; frame++ {
+		var frameSize int64
+		var err error
+		if opts.Poster != nil {
+			frameSize, err = addPosterPages(document, imagepath, frame, opts, pages)
+		} else {
+			frameSize, err = addFramePage(document, imagepath, frame, cover && frame == 0, opts, pages)
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += frameSize
+	}
+	return size, nil
+}
+
+// Shrink rect by padding on every side keeping its aspect ratio,
+// result is centered inside original rect
+func paddedRect(x, y, w, h, padding float64) (float64, float64, float64, float64) {
+	if padding <= 0 {
+		return x, y, w, h
+	}
+	scale := math.Max(0, math.Min((w-2*padding)/w, (h-2*padding)/h))
+	paddedW, paddedH := w*scale, h*scale
+	return x + (w-paddedW)/2, y + (h-paddedH)/2, paddedW, paddedH
+}
+
+// Move rect away from binding by spine width, which is at left edge of
+// odd pages and right edge of even ones. Rect is shrunk keeping its
+// aspect ratio and centered in remaining area
+func spineRect(page int, x, y, w, h, spine float64) (float64, float64, float64, float64) {
+	if spine <= 0 {
+		return x, y, w, h
+	}
+	areaX := x
+	if page%2 == 1 {
+		areaX += spine
+	}
+	offsetX, offsetY, fitW, fitH := fitRect(math.Max(w-spine, 0), h, w, h)
+	return areaX + offsetX, y + offsetY, fitW, fitH
+}
+
+// Center rect of w x h inside area of areaW x areaH,
+// rect is shrunk keeping its aspect ratio when it does not fit
+func fitRect(areaW, areaH, w, h float64) (float64, float64, float64, float64) {
+	scale := math.Min(1, math.Min(areaW/w, areaH/h))
+	w, h = w*scale, h*scale
+	return (areaW - w) / 2, (areaH - h) / 2, w, h
+}
+
+// Center rect of w x h inside area of areaW x areaH scaled
+// up or down keeping its aspect ratio, so that it just fits
+func letterboxRect(areaW, areaH, w, h float64) (float64, float64, float64, float64) {
+	scale := math.Min(areaW/w, areaH/h)
+	w, h = w*scale, h*scale
+	return (areaW - w) / 2, (areaH - h) / 2, w, h
+}
+
+// Get page of template size turned to orientation, portrait or landscape
+func orientedSize(w, h float64, orientation string) (float64, float64) {
+	if (orientation == "portrait") != (w < h) {
+		return h, w
+	}
+	return w, h
+}
+
+// Parse aspect ratio given as W:H, e.g. 3:4, into width divided by height
+func parseAspectRatio(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) == 2 {
+		w, errW := strconv.ParseFloat(parts[0], 64)
+		h, errH := strconv.ParseFloat(parts[1], 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return w / h, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid aspect-ratio %q, expected W:H, e.g. 3:4", value)
+}
+
+// Add single frame of image as page, cover is placed
+// at double template width as fold-out spread
+func addFramePage(document *gofpdf.Fpdf, imagepath string, frame int, cover bool, opts *Options, pages pageLog) (int64, error) {
+	if skip, err := skipBlankFrame(imagepath, frame, opts); skip || err != nil {
+		return 0, err
+	}
+	img := prepareImage(document, imagepath, frame, opts)
+	if err := checkPdfState(document, "embedding "+imagepath); err != nil {
+		return 0, err
+	}
+	degrees := imageRotation(imagepath, opts)
+	if opts.WriteCorrectedImages != "" {
+		if err := writeCorrectedImage(opts.WriteCorrectedImages, imagepath, frame, img, degrees, opts); err != nil {
+			return 0, err
+		}
+	}
+	imageW, imageH := img.w, img.h
+	if swapsSides(degrees) {
+		imageW, imageH = imageH, imageW
+	}
+	// -output-dpi sizes page by source pixels
+	sourceW, sourceH := img.sourceSize()
+	if swapsSides(degrees) {
+		sourceW, sourceH = sourceH, sourceW
+	}
+	resW, resH := opts.pageSize(sourceW, sourceH)
+	if cover && opts.OutputDpi == 0 {
+		resW, resH = optimalPageSize(2*opts.Template.Wd, opts.Template.Ht, imageW, imageH)
+	}
+	x, y, w, h := 0.0, opts.ImageGapAbove, resW, resH
+	if opts.SmartResize && opts.OutputDpi == 0 && !cover {
+		var nativeW, nativeH float64
+		resW, resH, nativeW, nativeH = nativePageSize(opts.Template, imageW, imageH)
+		x, y, w, h = (resW-nativeW)/2, y+(resH-nativeH)/2, nativeW, nativeH
+	}
+	if opts.Ratio > 0 && !cover {
+		resH = resW / opts.Ratio
+		x, y, w, h = fitRect(resW, resH, w, h)
+		y += opts.ImageGapAbove
+	}
+	if opts.ForceOrientation != "" && !cover {
+		resW, resH = orientedSize(opts.Template.Wd, opts.Template.Ht, opts.ForceOrientation)
+		x, y, w, h = letterboxRect(resW, resH, w, h)
+		y += opts.ImageGapAbove
+	}
+	// gaps extend page, so image keeps its size
+	pageH := opts.ImageGapAbove + resH + opts.ImageGapBelow
+	document.AddPageFormat("P", gofpdf.SizeType{Wd: resW, Ht: pageH})
+	if (opts.ImagePadding > 0 || opts.Ratio > 0 || opts.ForceOrientation != "") && opts.PaddingColor != white {
+		c := opts.PaddingColor
+		document.SetFillColor(c.r, c.g, c.b)
+		document.Rect(0, 0, resW, pageH, "F")
+	}
+	x, y, w, h = paddedRect(x, y, w, h, opts.ImagePadding)
+	x, y, w, h = spineRect(document.PageNo(), x, y, w, h, opts.SpineWidth)
+	if opts.CenterOfGravity && !cover {
+		pixels := img.decoded
+		if pixels == nil {
+			pixels = decodeFrame(imagepath, frame)
+		}
+		fx, fy := gravityCenter(rotateImage(pixels, degrees), uint8(opts.TrimTolerance))
+		x, y = gravityRect(w, h, 0, opts.ImageGapAbove, resW, resH, fx, fy)
+	}
+	if opts.Shadow > 0 {
+		drawShadow(document, x, y, w, h, opts)
+	}
+	placeImage(document, img, x, y, w, h, degrees)
+	if opts.OverlayImage != "" {
+		drawOverlay(document, opts)
+	}
+	if err := checkPdfState(document, "adding page for "+imagepath); err != nil {
+		return 0, err
+	}
+	pages[document.PageNo()] = append(pages[document.PageNo()], pageRecord{
+		imagepath: imagepath,
+		frame:     frame,
+		decoded:   img.decoded,
+		x:         x,
+		y:         y,
+		w:         w,
+		h:         h,
+		degrees:   degrees,
+	})
+	return img.size, nil
+}
+
+// Get accumulated error of document, gofpdf skips all operations
+// after first failure, so it is checked after each significant one
+func checkPdfState(document *gofpdf.Fpdf, context string) error {
+	if err := document.Error(); err != nil {
+		return fmt.Errorf("%s: %v", context, err)
+	}
+	return nil
 }
 
 // Initialize new pdf file with custom size in mm
 func createDocument(w, h float64) *gofpdf.Fpdf {
-	return gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "mm", Size: gofpdf.SizeType{Wd: w, Ht: h}})
+	document := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "mm", Size: gofpdf.SizeType{Wd: w, Ht: h}})
+	// every page is added explicitly, text must never spill over
+	document.SetAutoPageBreak(false, 0)
+	return document
+}
+
+// Make output byte-identical for identical input by fixing
+// timestamps and producer, resource catalogs are sorted
+// instead of following map iteration order. Images of equal
+// width are put in order by sortImageObjects on output
+func makeDeterministic(document *gofpdf.Fpdf) {
+	epoch := time.Unix(0, 0).UTC()
+	document.SetCreationDate(epoch)
+	document.SetModificationDate(epoch)
+	document.SetProducer("imgdir2pdf", false)
+	document.SetCatalogSort(true)
 }
 
 // very simplistic size determination algorithm
@@ -140,40 +474,285 @@ func optimalPageSize(templateW, templateH, givenW, givenH float64) (w, h float64
 	return w, h
 }
 
-// Add images from paths into single pdf
-func processImages(paths []string, saveAs string) {
-	if len(paths) < 1 {
-		panic("No suitable files in given directory.")
+// Get size of page and of image placed on it for image of w x h pixels
+// never enlarged beyond 96 dpi. Small images get template sized page
+// to be centered on, page grows only in height for tall images
+func nativePageSize(template gofpdf.SizeType, w, h float64) (pageW, pageH, imageW, imageH float64) {
+	pageW, pageH = optimalPageSize(template.Wd, template.Ht, w, h)
+	imageW, imageH = w/screenDpi*mmPerInch, h/screenDpi*mmPerInch
+	if imageW >= pageW {
+		return pageW, pageH, pageW, pageH
 	}
-	firstW, firstH := getImageSize(paths[0])
-	pdf := createDocument(optimalPageSize(a4Width, a4Height, firstW, firstH))
-	for _, elem := range paths {
-		addImagePage(pdf, elem)
+	return pageW, math.Max(template.Ht, imageH), imageW, imageH
+}
+
+// Get size of page for image of w x h pixels, either at output
+// dpi or scaled to template width
+func (opts *Options) pageSize(w, h float64) (float64, float64) {
+	if opts.OutputDpi > 0 {
+		return w / opts.OutputDpi * mmPerInch, h / opts.OutputDpi * mmPerInch
+	}
+	return optimalPageSize(opts.Template.Wd, opts.Template.Ht, w, h)
+}
+
+// Add images from all chapters into single pdf,
+// or several ones when splitting by size
+func processChapters(chapters []chapter, saveAs string, opts *Options) error {
+	if err := checkImageCount(countImages(chapters), opts); err != nil {
+		return err
+	}
+	texts := renderedTexts(chapters, opts)
+	var pdf *gofpdf.Fpdf
+	var font textFont
+	var estimator sizeEstimator
+	var pages pageLog
+	part := 0
+	// first image of output becomes fold-out cover
+	coverAdded := false
+	var toc *chapterToc
+	// value of -split-on-change property of previous image
+	var property string
+	// finish current document and write it
+	finish := func(saveAs string) error {
+		if opts.SummaryPage {
+			if err := addSummaryPages(pdf, font, opts, pages); err != nil {
+				return err
+			}
+		}
+		return writeDocument(pdf, saveAs, opts, pages)
+	}
+	for index, ch := range chapters {
+		for i, elem := range ch.paths {
+			if opts.SplitOnChange != "" {
+				previous := property
+				property = imageProperty(elem, opts.SplitOnChange, opts)
+				if pdf != nil && property != previous {
+					if toc != nil {
+						toc.abandon(i)
+						toc = nil
+					}
+					err := finish(partFilename(expandOutputName(saveAs, opts, pdf.PageNo()), part))
+					if err != nil {
+						return err
+					}
+					pdf = nil
+				}
+			}
+			if pdf == nil {
+				var err error
+				if pdf, font, pages, err = startDocument(elem, texts, opts); err != nil {
+					return err
+				}
+				estimator = newSizeEstimator(opts.SplitBySizeMB)
+				part++
+			}
+			if i == 0 && ch.name != "" && opts.ChapterSeparator != "" {
+				addSeparatorPage(pdf, opts, font, chapterTitle(opts.ChapterSeparator, ch.name))
+				if err := checkPdfState(pdf, "adding separator of chapter "+ch.name); err != nil {
+					return err
+				}
+			}
+			if i == 0 {
+				toc = nil
+				if ch.name != "" && opts.ChapterTocPerChapter {
+					toc = addChapterToc(pdf, opts, font, index, ch)
+				}
+			}
+			pageCount := pdf.PageNo()
+			size, err := addImagePage(pdf, elem, opts.FoldCover && !coverAdded, opts, pages)
+			coverAdded = true
+			if err != nil {
+				return err
+			}
+			if toc != nil {
+				toc.resolve(i, pageCount)
+			}
+			// bookmark only pages which were added, blank ones are skipped
+			if opts.UsePngMetadata && pdf.PageNo() > pageCount {
+				addPngBookmark(pdf, font, elem, opts)
+			}
+			if estimator.addPage(size) {
+				if toc != nil {
+					toc.abandon(i + 1)
+					toc = nil
+				}
+				err = finish(partFilename(expandOutputName(saveAs, opts, pdf.PageNo()), part))
+				if err != nil {
+					return err
+				}
+				pdf = nil
+			}
+		}
+	}
+	if pdf == nil {
+		return nil
+	}
+	saveAs = expandOutputName(saveAs, opts, pdf.PageNo())
+	if opts.splits() {
+		saveAs = partFilename(saveAs, part)
+	}
+	return finish(saveAs)
+}
+
+// Create document whose first page is sized for image elem,
+// set up with font for texts and page numbers
+func startDocument(elem string, texts []string, opts *Options) (*gofpdf.Fpdf, textFont, pageLog, error) {
+	firstW, firstH := getImageSize(elem)
+	pdf := createDocument(opts.pageSize(firstW, firstH))
+	if opts.Deterministic {
+		makeDeterministic(pdf)
+	}
+	pdf.SetCompression(opts.OutputCompressionFilter == "flate")
+	setupViewer(pdf, opts)
+	if opts.UsePngMetadata {
+		setPngMetadata(pdf, elem, opts)
+	}
+	font := setupFont(pdf, opts, texts)
+	if err := checkPdfState(pdf, "setting up document"); err != nil {
+		return nil, font, nil, err
+	}
+	pages := make(pageLog)
+	if opts.PageNumbers {
+		setupPageNumbers(pdf, font, opts, pages)
+	}
+	return pdf, font, pages, nil
+}
+
+// Check if output may be split into numbered parts
+func (opts *Options) splits() bool {
+	return opts.SplitBySizeMB > 0 || opts.SplitOnChange != ""
+}
+
+// Write finished pdf to file
+func writeDocument(document *gofpdf.Fpdf, saveAs string, opts *Options, pages pageLog) error {
+	var dests map[string]int
+	if opts.NamedDestinations {
+		dests = namedDestinations(pages)
+	}
+	entries := append(viewerPreferences(opts), destinationsEntry(dests)...)
+	entries = append(entries, languageEntry(documentLanguage(pages, opts))...)
+	var err error
+	if opts.OutputBase64 {
+		err = writeBase64Document(document, entries, opts.Deterministic, opts.Base64LineLength)
+	} else if len(entries) > 0 || opts.Deterministic {
+		err = writePatchedDocument(document, saveAs, entries, opts.Deterministic)
+	} else {
+		err = document.OutputFileAndClose(saveAs)
 	}
-	err := pdf.OutputFileAndClose(saveAs)
 	if err != nil {
-		fmt.Printf("Error writing pdf: %v", err)
+		return fmt.Errorf("Error writing pdf: %v", err)
 	}
+	if opts.MergePdf != "" {
+		if err = mergePdf(opts.MergePdf, saveAs, opts.Temp); err != nil {
+			return err
+		}
+	}
+	if opts.NamedDestIndex != "" {
+		if err = writeDestinationIndex(opts.NamedDestIndex, dests); err != nil {
+			return err
+		}
+	}
+	if opts.PostProcessScript != "" {
+		return runPostProcess(opts.PostProcessScript, saveAs)
+	}
+	return nil
+}
+
+// Write pdf with entries gofpdf cannot produce, such as viewer
+// preferences, added to catalog after generation. Deterministic
+// output gets image objects in stable order
+func writePatchedDocument(document *gofpdf.Fpdf, saveAs string, entries []byte, deterministic bool) error {
+	data, err := documentBytes(document, entries, deterministic)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(saveAs, data, 0644)
+}
+
+// Generate pdf in memory with catalog entries added,
+// image objects are sorted when deterministic
+func documentBytes(document *gofpdf.Fpdf, entries []byte, deterministic bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := document.Output(&buf); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	if deterministic {
+		var err error
+		if data, err = sortImageObjects(data); err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return data, nil
+	}
+	return insertCatalogEntries(data, entries)
 }
 
 // Construct absolute path of resulting pdf as
 // base folder of 'basepath'
 // i.e. /some/folder/ will turn into /abs/path/some/folder/folder.pdf
-func getOutFilename(basepath string) string {
+// -output-dir takes precedence over -output
+func getOutFilename(basepath string, opts *Options) string {
+	if opts.Output != "" && opts.OutputDir == "" {
+		return opts.Output
+	}
+	return opts.withBasename(getOutputPath(basepath, opts.OutputDir, opts.RenameOutputSuffix+".pdf"))
+}
+
+// Replace file name of default output path by -output-basename, if set
+func (opts *Options) withBasename(saveAs string) string {
+	if opts.OutputBasename == "" {
+		return saveAs
+	}
+	name := strings.TrimSuffix(opts.OutputBasename, ".pdf") + opts.RenameOutputSuffix + ".pdf"
+	return filepath.Join(filepath.Dir(saveAs), name)
+}
+
+// Get path of resulting pdf for video or project file, by default
+// it is saved next to source and named after it
+func sourceOutFilename(source string, opts *Options) string {
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	if opts.Output == "" && opts.OutputDir == "" {
+		return opts.withBasename(base + opts.RenameOutputSuffix + ".pdf")
+	}
+	return getOutFilename(base, opts)
+}
+
+// Construct absolute path of generated file named after base folder
+// of 'basepath' with suffix, e.g. folder.pdf or folder_stats.json.
+// Files go to outputDir when set, otherwise into 'basepath' itself
+func getOutputPath(basepath, outputDir, suffix string) string {
 	resultPath, err := filepath.Abs(basepath)
 	if err != nil {
 		panic(err)
 	}
 	basename := filepath.Base(resultPath)
-	return filepath.Join(resultPath, fmt.Sprintf("%s.pdf", basename))
+	if outputDir != "" {
+		resultPath = outputDir
+		if err = os.MkdirAll(outputDir, 0755); err != nil {
+			panic(err)
+		}
+	}
+	return filepath.Join(resultPath, basename+suffix)
 }
 
 // Main logic of program
 func main() {
-	if len(os.Args) <= 1 {
-		printHelp()
-		return
+	args := os.Args[1:]
+	run := runConvert
+	if len(args) > 0 {
+		if command, ok := subcommands[args[0]]; ok {
+			run, args = command, args[1:]
+		}
+	}
+	if err := run(args); err != nil {
+		fmt.Println(err)
+		// failed post-process script decides exit code
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			os.Exit(exitErr.ExitCode())
+		}
+		os.Exit(1)
 	}
-	dir := os.Args[1]
-	processImages(lsdir(dir, imageFormats[:]), getOutFilename(dir))
 }
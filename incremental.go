@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of cache file kept in output directory by default
+const cacheFilename = ".imgdir2pdf-cache.json"
+
+// Cached state of processed image
+type cacheEntry struct {
+	SHA256     string `json:"sha256"`
+	ModTime    int64  `json:"mtime"`
+	Size       int64  `json:"size"`
+	PageOffset int    `json:"pdf_page_offset"`
+	// ratio of processed to source pixels of resampled frames
+	Scales map[int]float64 `json:"scales,omitempty"`
+}
+
+// Processed images of previous runs, their png data is
+// stored in directory next to cache file named after it
+type imageCache struct {
+	// processing options images were produced with
+	Settings string                `json:"settings"`
+	Images   map[string]cacheEntry `json:"images"`
+	filename string
+	dataDir  string
+	reused   int
+	stored   int
+}
+
+// Get cache file used for resulting pdf saveAs
+func cacheFile(saveAs string, opts *Options) string {
+	if opts.IncrementalCache != "" {
+		return opts.IncrementalCache
+	}
+	return filepath.Join(filepath.Dir(saveAs), cacheFilename)
+}
+
+// Describe options which change processed images, cache
+// made with different ones is discarded
+func cacheSettings(opts *Options) string {
+	var normalize pixelSize
+	if opts.Normalize != nil {
+		normalize = *opts.Normalize
+	}
+	return fmt.Sprint(opts.Template.Wd, opts.TrimWhitespace, opts.TrimTolerance, opts.OptimizeForScreen,
+		normalize, opts.PaddingColor, opts.ImageResolutionLevel, opts.ForceType, opts.Rotations, opts.RotationPattern, opts.AutoOrientLandscape,
+		opts.DetectDocumentType)
+}
+
+// Load cache of previous run, missing or outdated cache gives empty one
+func loadImageCache(filename string, opts *Options) (*imageCache, error) {
+	cache := &imageCache{
+		Settings: cacheSettings(opts),
+		Images:   make(map[string]cacheEntry),
+		filename: filename,
+		dataDir:  strings.TrimSuffix(filename, filepath.Ext(filename)),
+	}
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var previous imageCache
+	if err = json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("invalid cache %s: %v", filename, err)
+	}
+	if previous.Settings == cache.Settings && previous.Images != nil {
+		cache.Images = previous.Images
+	}
+	return cache, nil
+}
+
+// Get path of stored png data of image frame
+func (cache *imageCache) dataFile(entry cacheEntry, frame int) string {
+	// extension keeps data out of converted images with -recursive
+	return filepath.Join(cache.dataDir, fmt.Sprintf("%s-%d.cache", entry.SHA256, frame))
+}
+
+// Get png data of image frame processed in previous run and its
+// scale, image must have same size and modification time
+func (cache *imageCache) lookup(imagepath string, frame int) ([]byte, float64, bool) {
+	entry, ok := cache.Images[imagepath]
+	if !ok {
+		return nil, 0, false
+	}
+	stat, err := os.Stat(imagepath)
+	if err != nil || stat.Size() != entry.Size || stat.ModTime().UnixNano() != entry.ModTime {
+		return nil, 0, false
+	}
+	data, err := ioutil.ReadFile(cache.dataFile(entry, frame))
+	if err != nil {
+		return nil, 0, false
+	}
+	cache.reused++
+	return data, entry.Scales[frame], true
+}
+
+// Remember png data of processed image frame with its scale placed on page
+func (cache *imageCache) store(imagepath string, frame int, data []byte, scale float64, page int) {
+	stat, err := os.Stat(imagepath)
+	if err != nil {
+		panic(err)
+	}
+	sum, err := fileChecksum(imagepath, 64)
+	if err != nil {
+		panic(err)
+	}
+	entry := cacheEntry{SHA256: sum, ModTime: stat.ModTime().UnixNano(), Size: stat.Size(), PageOffset: page}
+	if previous, ok := cache.Images[imagepath]; ok && frame > 0 {
+		// page of image is the one of its first frame
+		entry.PageOffset = previous.PageOffset
+		entry.Scales = previous.Scales
+	}
+	if scale != 1 {
+		if entry.Scales == nil {
+			entry.Scales = make(map[int]float64)
+		}
+		entry.Scales[frame] = scale
+	}
+	if err = os.MkdirAll(cache.dataDir, 0755); err != nil {
+		panic(err)
+	}
+	if err = ioutil.WriteFile(cache.dataFile(entry, frame), data, 0644); err != nil {
+		panic(err)
+	}
+	cache.Images[imagepath] = entry
+	cache.stored++
+}
+
+// Write cache file for next run
+func (cache *imageCache) save() error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "incremental: %d images reused, %d processed\n", cache.reused, cache.stored)
+	return ioutil.WriteFile(cache.filename, append(data, '\n'), 0644)
+}
+
+// Get pixel size of png data
+func pngSize(data []byte) (w, h float64) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return float64(config.Width), float64(config.Height)
+}
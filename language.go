@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ISO 15924 codes of scripts which mostly identify language,
+// Latin is shared by too many languages to guess one
+var detectableScripts = map[string]string{
+	"Arabic":     "Arab",
+	"Armenian":   "Armn",
+	"Cyrillic":   "Cyrl",
+	"Devanagari": "Deva",
+	"Georgian":   "Geor",
+	"Greek":      "Grek",
+	"Han":        "Hani",
+	"Hangul":     "Hang",
+	"Hebrew":     "Hebr",
+	"Hiragana":   "Jpan",
+	"Katakana":   "Jpan",
+	"Thai":       "Thai",
+}
+
+// Parse BCP 47 tag given to -language into canonical form
+func parseLanguage(tag string) (string, error) {
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid language %q: %v", tag, err)
+	}
+	return parsed.String(), nil
+}
+
+// Guess language of texts from their predominant script, the most
+// likely language of script is taken. Empty when no script prevails
+// over Latin letters
+func detectLanguage(texts []string) string {
+	counts := make(map[string]int)
+	for _, text := range texts {
+		for _, r := range text {
+			if unicode.Is(unicode.Latin, r) {
+				counts["Latn"]++
+				continue
+			}
+			for name, code := range detectableScripts {
+				if unicode.Is(unicode.Scripts[name], r) {
+					counts[code]++
+					break
+				}
+			}
+		}
+	}
+	// japanese mixes kana with Han characters
+	if counts["Jpan"] > 0 {
+		counts["Jpan"] += counts["Hani"]
+		delete(counts, "Hani")
+	}
+	codes := make([]string, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	// ties are broken by code to keep result stable
+	sort.Slice(codes, func(i, j int) bool {
+		if counts[codes[i]] != counts[codes[j]] {
+			return counts[codes[i]] > counts[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	if len(codes) == 0 || codes[0] == "Latn" {
+		return ""
+	}
+	base, _ := language.Make("und-" + codes[0]).Base()
+	return base.String()
+}
+
+// Get texts describing images of document: file names
+// and EXIF descriptions
+func captionTexts(pages pageLog) []string {
+	var texts []string
+	for _, recs := range pages {
+		for _, rec := range recs {
+			base := filepath.Base(rec.imagepath)
+			texts = append(texts, strings.TrimSuffix(base, filepath.Ext(base)))
+			if description := exifDescription(rec.imagepath); description != "" {
+				texts = append(texts, description)
+			}
+		}
+	}
+	return texts
+}
+
+// Get language of document, detected one takes precedence over -language
+func documentLanguage(pages pageLog, opts *Options) string {
+	if opts.DetectLanguage {
+		if detected := detectLanguage(captionTexts(pages)); detected != "" {
+			return detected
+		}
+	}
+	return opts.Language
+}
+
+// Get /Lang catalog entry, nil for unknown language
+func languageEntry(tag string) []byte {
+	if tag == "" {
+		return nil
+	}
+	return []byte(fmt.Sprintf("/Lang (%s)\n", tag))
+}
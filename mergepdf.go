@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Replace generated pdf at saveAs with new file holding pages of
+// existing pdf followed by generated ones. Merging is done by
+// mutool of mupdf into temporary directory, saveAs is only replaced
+// once it succeeded. Existing file is left untouched
+func mergePdf(existing, saveAs string, temp *tempFiles) error {
+	workDir, err := temp.mkdir("merge")
+	if err != nil {
+		return err
+	}
+	merged := filepath.Join(workDir, filepath.Base(saveAs))
+	cmd := exec.Command("mutool", "merge", "-o", merged, existing, saveAs)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("mutool failed to merge %s: %v", existing, err)
+	}
+	// temporary directory may be on other filesystem, so it is copied
+	data, err := ioutil.ReadFile(merged)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(saveAs, data, 0644)
+}
+
+// Check that pdf to merge exists and mutool is available
+func checkMergePdf(existing string) error {
+	if _, err := os.Stat(existing); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("mutool"); err != nil {
+		return fmt.Errorf("merge-pdf requires mutool of mupdf in PATH: %v", err)
+	}
+	return nil
+}
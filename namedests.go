@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Characters which are replaced in destination names
+var destNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Get name of destination for image, e.g. img001 for "img 001.png"
+func destinationName(imagepath string) string {
+	base := filepath.Base(imagepath)
+	name := destNameUnsafe.ReplaceAllString(strings.TrimSuffix(base, filepath.Ext(base)), "_")
+	if name == "" {
+		return "page"
+	}
+	return name
+}
+
+// Name every image page of document after file of its first image.
+// Repeated names, e.g. frames of tiff, get number suffix starting from 2
+func namedDestinations(pages pageLog) map[string]int {
+	numbers := make([]int, 0, len(pages))
+	for number := range pages {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	dests := make(map[string]int, len(pages))
+	for _, number := range numbers {
+		base := destinationName(pages[number][0].imagepath)
+		name := base
+		for i := 2; dests[name] != 0; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		dests[name] = number
+	}
+	return dests
+}
+
+// Get /Dests catalog entry with destinations in name order
+func destinationsEntry(dests map[string]int) []byte {
+	if len(dests) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(dests))
+	for name := range dests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var entry bytes.Buffer
+	entry.WriteString("/Dests <<")
+	for _, name := range names {
+		// gofpdf writes page n as object 1+2n
+		fmt.Fprintf(&entry, " /%s [%d 0 R /Fit]", name, 1+2*dests[name])
+	}
+	entry.WriteString(" >>\n")
+	return entry.Bytes()
+}
+
+// Save destinations as json object of name to page number
+func writeDestinationIndex(filename string, dests map[string]int) error {
+	data, err := json.MarshalIndent(dests, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Options holds all settings controlling a conversion
+type Options struct {
+	// Template page size, images are scaled to its width
+	PageSize string
+	Template gofpdf.SizeType `json:"-"`
+	// Size pages by image pixels at given dpi instead of template, 0 disables
+	OutputDpi float64
+	// Place images smaller than template at native size instead of enlarging
+	SmartResize bool
+	// Path of resulting pdf, by default it is saved in DIR
+	Output string
+	// Directory for all generated files, overrides Output
+	OutputDir string
+	// Existing pdf whose pages precede generated ones in new output file
+	MergePdf string
+	// Format of resulting document: pdf, docx or epub
+	OutputFormat string
+	// Write pdf to stdout as base64 instead of file, broken
+	// into lines of Base64LineLength unless it is 0
+	OutputBase64     bool
+	Base64LineLength int
+	// Go template for name of resulting pdf, ignored with Output
+	OutputNameTemplate string
+	// File name replacing DIR in default pdf name, directory is kept
+	OutputBasename string
+	// Appended to default pdf name before extension, e.g. DIR_v2.pdf
+	RenameOutputSuffix string
+	NameTemplate       *template.Template `json:"-"`
+	// Values for NameTemplate, nil when it is not used
+	OutputNames *outputNameData `json:"-"`
+	// Extensions of files to convert, supported formats by default
+	IncludeExtension string
+	ExcludeExtension string
+	Formats          []string `json:"-"`
+	// How viewers open document, zoom is fit or percentage
+	InitialZoom string
+	ZoomPercent float64 `json:"-"`
+	PageLayout  string
+	HideToolbar bool
+	HideMenubar bool
+	// Treat all files in directory as images of this format
+	ForceType string
+	// Directory whose images are interleaved with those of DIR,
+	// CollateReverse takes images of DIR from the end
+	Collate        string
+	CollateReverse bool
+	// Shuffle images of every chapter, seed 0 is taken from current time
+	RandomOrder bool
+	RandomSeed  int64
+	// Convert only first and/or last images, 0 disables
+	FirstN int
+	LastN  int
+	// File listing image names in desired order
+	OrderFile               string
+	OrderFileFuzzy          bool
+	OrderFileFuzzyThreshold int
+	// Do not pick up .order file of every input directory
+	IgnoreOrderFile bool
+	// Order of files: name or xattr:ATTR_NAME
+	Sort      string
+	SortXattr string `json:"-"`
+	// Order files as arranged in Finder window
+	UseDsStore bool
+	// Split names into segments sorted separately
+	NaturalSortDelimiter string
+	// Convert frames of video instead of directory
+	FromVideo    string
+	FromVideoFps float64
+	// Manifest of image checksums to verify before converting
+	VerifyChecksums string
+	// Report sharpness, noise and contrast of images
+	QualityReport     bool
+	QualityReportFile string
+	// Directory to export ICC profile of first image to
+	ColorProfileOutput string
+	// Bounds of number of found images, zero maximum is no limit
+	MinImageCount int
+	MaxImageCount int
+	// Treat warnings as errors
+	StrictMode bool
+	Warn       WarnHandler `json:"-"`
+	// Print details of processing of every image on stderr
+	Verbose bool
+	// Shell command run on every written pdf
+	PostProcessScript string
+	// Parent directory of temporary files, system default when empty
+	TempDir string
+	Temp    *tempFiles `json:"-"`
+	// Leave temporary files in place and print their paths
+	KeepTemp bool
+	// Project file given instead of DIR, nil otherwise
+	Project *project `json:"-"`
+	// Only print EXIF orientation of all images
+	ImageRotationReport bool
+	// Produce byte-identical pdf for identical input
+	Deterministic bool
+	// Stream compression of pdf: flate or none
+	OutputCompressionFilter string
+	// Name every image page after its file, NamedDestIndex
+	// receives json of names to page numbers
+	NamedDestinations bool
+	NamedDestIndex    string
+	// Document language as BCP 47 tag, DetectLanguage guesses it
+	// from script of file names and EXIF descriptions
+	Language       string
+	DetectLanguage bool
+	// Take document properties and bookmarks from png text chunks
+	UsePngMetadata bool
+	// Treat each subdirectory of DIR as a separate chapter
+	Recursive bool
+	// Skip directories containing .nomedia file
+	RespectNomedia bool
+	// Text of page inserted before each chapter, %s is replaced by chapter name
+	ChapterSeparator string
+	// Insert page listing images of each chapter at its start
+	ChapterTocPerChapter bool
+	// Typography of chapter separator title, size is in points
+	ChapterTitleFontSize         float64
+	ChapterTitleFontFamily       string
+	ChapterTitleAlignment        string
+	ChapterTitleVerticalPosition string
+	// Start new chapter when numbers in file names jump by more than ChapterGap
+	AutoSplitChapters bool
+	ChapterGap        uint64
+	// Crop near white borders, channels within TrimTolerance from 255 count as white
+	TrimWhitespace bool
+	TrimTolerance  uint
+	// Downscale images exceeding 96 dpi on resulting page
+	OptimizeForScreen bool
+	// Reuse images processed in previous run from cache file,
+	// by default .imgdir2pdf-cache.json next to resulting pdf
+	Incremental      bool
+	IncrementalCache string
+	Cache            *imageCache `json:"-"`
+	// Write low quality DIR.preview.pdf of first images before conversion
+	PreviewPages int
+	Preview      bool `json:"-"`
+	// Resize every image to WxH pixels, letterboxed with image-padding-color
+	NormalizeDimensions string
+	Normalize           *pixelSize `json:"-"`
+	// Classify images as photo, text or mixed, text is binarized
+	DetectDocumentType bool
+	// Remove EXIF, XMP and text chunks from embedded jpeg and png
+	StripMetadata bool
+	// Zlib level 1-9 for png images, 0 keeps their compression
+	PngCompression int
+	// Add every frame of multi-frame tiff as separate page
+	TiffAllFrames bool
+	// Resolution level of pyramidal tiff and dng to decode, 0 is the
+	// smallest, full the largest. Empty uses first directory
+	ImageResolutionLevel string
+	ResolutionLevel      int `json:"-"`
+	// Skip images whose luminance deviation is below BlankPageThreshold,
+	// KeepBlankPages only reports them
+	DetectBlankPages   bool
+	BlankPageThreshold float64
+	KeepBlankPages     bool
+	// Json file with clockwise rotations of images by file name
+	RotationMap string
+	Rotations   map[string]int `json:"-"`
+	// Regex capturing clockwise rotation from file name in group rotation
+	PreRotateByFilenamePattern string
+	RotationPattern            *regexp.Regexp `json:"-"`
+	// Rotate landscape images to fill width of portrait pages
+	AutoOrientLandscape bool
+	// Directory receiving images as they appear in pdf
+	WriteCorrectedImages string
+	// Compression of re-encoded tiff files: uncompressed or deflate
+	TiffCompression string
+	// Start new pdf when estimated size exceeds given megabytes, 0 disables
+	SplitBySizeMB float64
+	// Start new pdf when property of consecutive images changes
+	SplitOnChange string
+	// Split every image into NxM grid of pages for printing posters
+	PosterTile string
+	Poster     *posterGrid `json:"-"`
+	// Stack all images on one tall page, SinglePageGap mm apart
+	SinglePage    bool
+	SinglePageGap float64
+	// Write each image into separate pdf named after it
+	OnePdfPerImage bool
+	// Append index pages with SummaryCols thumbnails per row,
+	// SummaryThumbHeight mm high, labeled with page numbers
+	SummaryPage        bool
+	SummaryCols        int
+	SummaryThumbHeight float64
+	// Place first image at double template width as fold-out cover
+	FoldCover bool
+	// Blank space in mm above and below image on each page
+	ImageGapAbove float64
+	ImageGapBelow float64
+	// Whitespace in mm between page border and image, page keeps its size
+	ImagePadding      float64
+	ImagePaddingColor string
+	PaddingColor      rgb `json:"-"`
+	// Extra inner margin in mm for binding, left on odd and right on even pages
+	SpineWidth float64
+	// Center visual weight of image instead of its bounds on page
+	CenterOfGravity bool
+	// Proportions W:H of every image page, image is padded to match
+	AspectRatio string
+	Ratio       float64 `json:"-"`
+	// Make every image page portrait or landscape, image is letterboxed
+	ForceOrientation string
+	// Image such as logo placed over every image page at position in mm,
+	// zero width or height keeps its aspect ratio
+	OverlayImage string
+	OverlayX     float64
+	OverlayY     float64
+	OverlayW     float64
+	OverlayH     float64
+	// Drop shadow offset in mm below and right of image, 0 disables
+	Shadow      float64
+	ShadowColor string
+	ShadowRGB   rgb `json:"-"`
+	ShadowBlur  float64
+	// Render page numbers at PageNumberPosition, e.g. bottom-center
+	PageNumbers        bool
+	PageNumberPosition string
+	// Color of all rendered text, FontColorAuto picks black or white
+	// depending on image below text
+	FontColor     string
+	TextColor     rgb `json:"-"`
+	FontColorAuto bool
+	// TrueType font used for rendered text instead of core Helvetica
+	FontFile string
+	// Embed complete font file instead of used glyphs only
+	EmbedFullFonts bool
+	// Switch to full embedding when more distinct glyphs are used, 0 disables
+	SubsetEmbedThreshold int
+}
+
+// Define flags for all options, defaults are stored into opts
+func defineFlags(flags *flag.FlagSet, opts *Options) {
+	flags.StringVar(&opts.PageSize, "page-size", "A4",
+		"template page `SIZE`: "+strings.Join(pageSizeNames, ", ")+" or WxH in mm")
+	flags.Float64Var(&opts.OutputDpi, "output-dpi", 0,
+		"size every page by its image pixels at `DPI`, overrides page-size")
+	flags.BoolVar(&opts.SmartResize, "smart-resize", false,
+		"do not enlarge images beyond 96 dpi, small ones are centered on template page")
+	flags.StringVar(&opts.Output, "output", "",
+		"save resulting pdf as `FILE` instead of DIR/DIR.pdf")
+	flags.StringVar(&opts.Output, "o", "",
+		"shorthand for -output")
+	flags.StringVar(&opts.MergePdf, "merge-pdf", "",
+		"start resulting pdf with pages of existing `PDF`, which is left untouched")
+	flags.StringVar(&opts.OutputFormat, "output-format", "pdf",
+		"produce document in `FORMAT`: pdf, docx or fixed layout epub with one image per page")
+	flags.BoolVar(&opts.OutputBase64, "output-base64", false,
+		"write pdf to stdout encoded as base64 instead of file")
+	flags.IntVar(&opts.Base64LineLength, "base64-line-length", 0,
+		"break base64 output into lines of `N` characters, e.g. 76 for MIME, 0 disables")
+	flags.StringVar(&opts.OutputDir, "output-dir", "",
+		"write pdf and all other generated files into `DIR`, overrides -output")
+	flags.StringVar(&opts.OutputNameTemplate, "output-name-template", "",
+		"name resulting pdf by Go `TEMPLATE`, e.g. \"{{.DirName}}_{{.Date}}.pdf\"")
+	flags.StringVar(&opts.OutputBasename, "output-basename", "",
+		"save pdf as `NAME`.pdf in directory where DIR.pdf would go")
+	flags.StringVar(&opts.RenameOutputSuffix, "rename-output-suffix", "",
+		"append `SUFFIX` to default pdf name, e.g. _compressed gives DIR_compressed.pdf")
+	flags.StringVar(&opts.IncludeExtension, "include-extension", "",
+		"also convert files with comma separated extensions from `LIST`, e.g. \".jpe,.webp2\"")
+	flags.StringVar(&opts.ExcludeExtension, "exclude-extension", "",
+		"skip files with comma separated extensions from `LIST`, e.g. \".gif\"")
+	flags.StringVar(&opts.ForceType, "force-type", "",
+		"convert all files in DIR as images of `FORMAT`: png, jpeg, gif or tiff")
+	flags.StringVar(&opts.InitialZoom, "initial-zoom", "fit",
+		"open document at zoom `PERCENT`, fit shows whole page")
+	flags.StringVar(&opts.PageLayout, "page-layout", "",
+		"open document in `LAYOUT`: single, two-page or continuous")
+	flags.BoolVar(&opts.HideToolbar, "hide-toolbar", false,
+		"ask viewer to hide its toolbar")
+	flags.BoolVar(&opts.HideMenubar, "hide-menubar", false,
+		"ask viewer to hide its menu bar")
+	flags.StringVar(&opts.Collate, "collate", "",
+		"interleave images of `DIR2` with images of DIR, DIR2 going first")
+	flags.BoolVar(&opts.CollateReverse, "collate-reverse", false,
+		"take collated images of DIR in reverse order")
+	flags.BoolVar(&opts.RandomOrder, "random-order", false,
+		"shuffle images of every chapter, used seed is printed to stderr")
+	flags.Int64Var(&opts.RandomSeed, "random-seed", 0,
+		"shuffle with `SEED` for reproducible order, 0 uses current time")
+	flags.IntVar(&opts.FirstN, "first-n", 0,
+		"convert only first `N` images")
+	flags.IntVar(&opts.LastN, "last-n", 0,
+		"convert only last `N` images, with -first-n last N of first ones")
+	flags.StringVar(&opts.OrderFile, "order-file", "",
+		"place images in order of names listed in `FILE`, one per line")
+	flags.BoolVar(&opts.IgnoreOrderFile, "ignore-order-file", false,
+		"do not sort images by .order file found in input directories")
+	flags.BoolVar(&opts.OrderFileFuzzy, "order-file-fuzzy", false,
+		"match order file names without exact match to closest image name")
+	flags.IntVar(&opts.OrderFileFuzzyThreshold, "order-file-fuzzy-threshold", 2,
+		"maximum edit distance of fuzzy order file match")
+	flags.StringVar(&opts.Sort, "sort", "name",
+		"order files by `KEY`: name, natural-unicode or xattr:ATTR_NAME to sort by extended attribute")
+	flags.BoolVar(&opts.UseDsStore, "use-ds-store", false,
+		"order files as arranged by drag and drop in macOS Finder, read from .DS_Store")
+	flags.StringVar(&opts.NaturalSortDelimiter, "natural-sort-delimiter", "",
+		"sort every part of file names split by given delimiter numerically, e.g. -")
+	flags.StringVar(&opts.FromVideo, "from-video", "",
+		"convert frames of `VIDEO` extracted by ffmpeg instead of DIR")
+	flags.Float64Var(&opts.FromVideoFps, "from-video-fps", 1,
+		"number of frames extracted per second of video")
+	flags.BoolVar(&opts.QualityReport, "quality-report", false,
+		"print sharpness, noise and contrast of every image")
+	flags.StringVar(&opts.QualityReportFile, "quality-report-file", "",
+		"write quality metrics of every image as json to `FILE`")
+	flags.StringVar(&opts.ColorProfileOutput, "color-profile-output", "",
+		"write ICC profile of first image to `DIR`/"+iccProfileName)
+	flags.IntVar(&opts.MinImageCount, "min-image-count", 1,
+		"fail when fewer than `N` images are found")
+	flags.IntVar(&opts.MaxImageCount, "max-image-count", 0,
+		"fail when more than `N` images are found, 0 is no limit")
+	flags.BoolVar(&opts.StrictMode, "strict-mode", false,
+		"fail with exit code 1 on first warning")
+	flags.BoolVar(&opts.Verbose, "verbose", false,
+		"print details of every processed image, e.g. its -detect-document-type class")
+	flags.StringVar(&opts.PostProcessScript, "post-process-script", "",
+		"run shell `COMMAND` with path of every written pdf appended")
+	flags.StringVar(&opts.TempDir, "temp-dir", "",
+		"write temporary files into `PATH`, e.g. RAM disk")
+	flags.BoolVar(&opts.KeepTemp, "keep-temp", false,
+		"keep temporary files for inspection and print their paths to stderr")
+	flags.StringVar(&opts.VerifyChecksums, "verify-checksums", "",
+		"abort unless all images match sha256sum or md5sum manifest `FILE`")
+	flags.BoolVar(&opts.ImageRotationReport, "image-rotation-report", false,
+		"print EXIF orientation of all images instead of converting them")
+	flags.StringVar(&opts.OutputCompressionFilter, "output-compression-filter", "flate",
+		"compress pdf content streams with `FILTER`: flate or none")
+	flags.BoolVar(&opts.NamedDestinations, "named-destinations", false,
+		"add named destination for every image page, e.g. doc.pdf#nameddest=img001")
+	flags.StringVar(&opts.NamedDestIndex, "named-dest-index", "",
+		"write json of named destinations to page numbers into `FILE`")
+	flags.StringVar(&opts.Language, "language", "",
+		"declare document language as BCP 47 `TAG`, e.g. en-US, for screen readers")
+	flags.BoolVar(&opts.DetectLanguage, "detect-language", false,
+		"guess document language from file names and EXIF descriptions, falling back to language")
+	flags.BoolVar(&opts.UsePngMetadata, "use-png-metadata", false,
+		"take pdf title, author and subject and page bookmarks from png text metadata")
+	flags.BoolVar(&opts.Deterministic, "deterministic", false,
+		"produce byte-identical pdf for identical input, timestamps are set to epoch")
+	flags.BoolVar(&opts.Recursive, "recursive", false,
+		"include subdirectories, each one becomes a chapter")
+	flags.BoolVar(&opts.RespectNomedia, "respect-nomedia", false,
+		"skip directories hidden from Android media scanner by .nomedia file")
+	flags.StringVar(&opts.ChapterSeparator, "chapter-separator", "",
+		"insert page with `TEXT` before each chapter, %s is replaced by chapter name")
+	flags.BoolVar(&opts.ChapterTocPerChapter, "chapter-toc-per-chapter", false,
+		"start each chapter with page listing its images and their pages within chapter")
+	flags.Float64Var(&opts.ChapterTitleFontSize, "chapter-title-font-size", 36,
+		"font size of chapter separator title in `PT`")
+	flags.StringVar(&opts.ChapterTitleFontFamily, "chapter-title-font-family", chapterTitleFont,
+		"core font `FAMILY` of chapter title: "+strings.Join(coreFontFamilies, ", ")+", ignored with -font")
+	flags.StringVar(&opts.ChapterTitleAlignment, "chapter-title-alignment", "center",
+		"horizontal `ALIGNMENT` of chapter title: left, center, right")
+	flags.StringVar(&opts.ChapterTitleVerticalPosition, "chapter-title-vertical-position", "center",
+		"vertical `POSITION` of chapter title: top-third, center, bottom-third")
+	flags.BoolVar(&opts.AutoSplitChapters, "auto-split-chapters", false,
+		"start new chapter where numbers in file names jump by more than chapter-gap")
+	flags.Uint64Var(&opts.ChapterGap, "chapter-gap", 10,
+		"minimal jump of file name numbers which starts new chapter")
+	flags.BoolVar(&opts.TrimWhitespace, "trim-whitespace", false,
+		"crop white borders of scanned images")
+	flags.UintVar(&opts.TrimTolerance, "trim-tolerance", 16,
+		"max distance of color channels from white which still counts as white, 0-255")
+	flags.BoolVar(&opts.OptimizeForScreen, "optimize-for-screen", false,
+		"downscale images to 96 dpi for smaller pdf intended for screen viewing")
+	flags.BoolVar(&opts.Incremental, "incremental", false,
+		"reuse processed images of unchanged files from previous run")
+	flags.StringVar(&opts.IncrementalCache, "incremental-cache", "",
+		"keep cache of -incremental in `FILE` instead of .imgdir2pdf-cache.json next to pdf")
+	flags.IntVar(&opts.PreviewPages, "preview-pages", 0,
+		"first write quick low quality preview pdf of first `N` images next to resulting one")
+	flags.StringVar(&opts.NormalizeDimensions, "normalize-dimensions", "",
+		"resize every image to `WxH` pixels keeping aspect ratio, e.g. 2480x3508 for A4 at 300 dpi")
+	flags.BoolVar(&opts.DetectDocumentType, "detect-document-type", false,
+		"classify images as photo, text or mixed and store text scans as black and white")
+	flags.BoolVar(&opts.StripMetadata, "strip-metadata", false,
+		"remove EXIF, XMP and text metadata from images before embedding")
+	flags.IntVar(&opts.PngCompression, "png-compression", 0,
+		"losslessly recompress png images at zlib `LEVEL` 1-9, 9 is smallest and slowest")
+	flags.BoolVar(&opts.TiffAllFrames, "tiff-all-frames", false,
+		"add every frame of multi-frame tiff as separate page")
+	flags.StringVar(&opts.ImageResolutionLevel, "image-resolution-level", "",
+		"decode resolution `LEVEL` of pyramidal tiff and dng: 0 is smallest, 1, 2, ... or full")
+	flags.BoolVar(&opts.DetectBlankPages, "detect-blank-pages", false,
+		"skip blank images, e.g. produced by scanner overrun")
+	flags.Float64Var(&opts.BlankPageThreshold, "blank-page-threshold", 5,
+		"image is blank when standard deviation of its luminance is below `N`")
+	flags.BoolVar(&opts.KeepBlankPages, "keep-blank-pages", false,
+		"keep detected blank pages and only report them")
+	flags.StringVar(&opts.RotationMap, "rotation-map", "",
+		"rotate images clockwise by degrees from json `FILE`, e.g. {\"img001.jpg\": 90}")
+	flags.StringVar(&opts.PreRotateByFilenamePattern, "pre-rotate-by-filename-pattern", "",
+		"rotate images clockwise by degrees captured from file name by `REGEX` group rotation, e.g. (?P<rotation>90|180|270)")
+	flags.BoolVar(&opts.AutoOrientLandscape, "auto-orient-landscape", false,
+		"rotate landscape images by 90 degrees to fill portrait pages")
+	flags.StringVar(&opts.WriteCorrectedImages, "write-corrected-images", "",
+		"also save rotated and processed images into `DIR` under their input names")
+	flags.StringVar(&opts.TiffCompression, "tiff-compression", "deflate",
+		"compress tiff files written by -write-corrected-images with `METHOD`: "+strings.Join(tiffCompressionNames, ", "))
+	flags.Float64Var(&opts.SplitBySizeMB, "split-by-size-mb", 0,
+		"split output into numbered pdfs of approximately `N` megabytes")
+	flags.StringVar(&opts.SplitOnChange, "split-on-change", "",
+		"start new numbered pdf when `PROPERTY` of images changes: "+strings.Join(splitProperties, ", "))
+	flags.StringVar(&opts.PosterTile, "poster-tile", "",
+		"split every image into `NxM` grid of N columns and M rows, one tile per page")
+	flags.BoolVar(&opts.SinglePage, "single-page", false,
+		"stack all images onto one tall page for continuous scrolling")
+	flags.Float64Var(&opts.SinglePageGap, "single-page-gap", 0,
+		"blank space in `MM` between images of -single-page")
+	flags.BoolVar(&opts.OnePdfPerImage, "one-pdf-per-image", false,
+		"write every image into its own single page pdf named after it")
+	flags.BoolVar(&opts.SummaryPage, "summary-page", false,
+		"append index page with thumbnails of all images labeled with page numbers")
+	flags.IntVar(&opts.SummaryCols, "summary-cols", 8,
+		"number of thumbnails per row on -summary-page, `N`")
+	flags.Float64Var(&opts.SummaryThumbHeight, "summary-thumb-height", 30,
+		"height of thumbnails on -summary-page in `MM`")
+	flags.BoolVar(&opts.FoldCover, "fold-cover", false,
+		"place first image at double page width as panoramic fold-out cover")
+	flags.Float64Var(&opts.ImageGapAbove, "image-gap-above", 0,
+		"blank space in `MM` between top page edge and image")
+	flags.Float64Var(&opts.ImageGapBelow, "image-gap-below", 0,
+		"blank space in `MM` between image and bottom page edge")
+	flags.Float64Var(&opts.ImagePadding, "image-padding", 0,
+		"whitespace in `MM` around image inside page, page size is kept")
+	flags.StringVar(&opts.ImagePaddingColor, "image-padding-color", "#FFFFFF",
+		"background color of image padding as `#RRGGBB`")
+	flags.Float64Var(&opts.SpineWidth, "spine-width", 0,
+		"reserve `MM` for binding at left edge of odd and right edge of even pages")
+	flags.BoolVar(&opts.CenterOfGravity, "center-of-gravity", false,
+		"align visual center of images smaller than page with page center")
+	flags.StringVar(&opts.AspectRatio, "aspect-ratio", "",
+		"give every image page proportions `W:H`, e.g. 3:4, padding with image-padding-color")
+	flags.StringVar(&opts.ForceOrientation, "force-orientation", "",
+		"make every image page template sized in `ORIENTATION` portrait or landscape, letterboxing images")
+	flags.StringVar(&opts.OverlayImage, "overlay-image", "",
+		"place png, jpeg or gif `FILE` over every image page, e.g. logo or stamp")
+	flags.Float64Var(&opts.OverlayX, "overlay-x", 10,
+		"distance of overlay image from left page edge in `MM`")
+	flags.Float64Var(&opts.OverlayY, "overlay-y", 10,
+		"distance of overlay image from top page edge in `MM`")
+	flags.Float64Var(&opts.OverlayW, "overlay-w", 30,
+		"width of overlay image in `MM`, 0 keeps aspect ratio")
+	flags.Float64Var(&opts.OverlayH, "overlay-h", 0,
+		"height of overlay image in `MM`, 0 keeps aspect ratio")
+	flags.Float64Var(&opts.Shadow, "shadow", 0,
+		"render drop shadow `OFFSET_MM` below and right of every image")
+	flags.StringVar(&opts.ShadowColor, "shadow-color", "#808080",
+		"color of drop shadow as `#RRGGBB`")
+	flags.Float64Var(&opts.ShadowBlur, "shadow-blur", 0,
+		"soften drop shadow edges over `MM`")
+	flags.BoolVar(&opts.PageNumbers, "page-numbers", false,
+		"print number on every page")
+	flags.StringVar(&opts.PageNumberPosition, "page-number-position", "bottom-center",
+		"place page numbers at `POSITION`: "+strings.Join(pageNumberPositions, ", "))
+	flags.StringVar(&opts.FontColor, "font-color", "#000000",
+		"color of rendered text as `#RRGGBB`")
+	flags.BoolVar(&opts.FontColorAuto, "font-color-auto", false,
+		"render text in black or white depending on luminance of image below")
+	flags.StringVar(&opts.FontFile, "font", "",
+		"render text with TrueType font from `FILE`, only used glyphs are embedded")
+	flags.BoolVar(&opts.EmbedFullFonts, "embed-full-fonts", false,
+		"embed complete font file as required by PDF/A, limits text to cp1252 charset")
+	flags.IntVar(&opts.SubsetEmbedThreshold, "subset-embed-threshold", 0,
+		"embed complete font when more than `N` distinct glyphs are used, 0 disables")
+}
+
+// Get options with all flags at their defaults
+func defaultOptions() *Options {
+	opts := &Options{}
+	defineFlags(flag.NewFlagSet("defaults", flag.ContinueOnError), opts)
+	return opts
+}
+
+// Parse command line arguments into options and input directory.
+// Values from -config file override defaults, explicit flags override both.
+// Returns nil options when there is nothing to process
+func parseArgs(args []string) (opts *Options, dir string, err error) {
+	opts = &Options{}
+	flags := flag.NewFlagSet("imgdir2pdf", flag.ExitOnError)
+	flags.SetOutput(os.Stdout)
+	flags.Usage = func() {
+		printHelp()
+		flags.PrintDefaults()
+	}
+	defineFlags(flags, opts)
+	profile := flags.String("output-profile", "",
+		"preset options for `PROFILE`: "+strings.Join(outputProfileNames, ", ")+", explicit flags take precedence")
+	config := flags.String("config", "",
+		"read options from json `FILE`, explicit flags take precedence")
+	completion := flags.String("completion", "",
+		"print completion script for `SHELL` and exit, only bash is supported")
+	initProjectFile := flags.Bool("init", false,
+		"create "+projectTemplateName+" template in current directory and exit")
+	selfTest := flags.Bool("test", false,
+		"convert embedded test images, validate result and exit")
+	if err = flags.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if *selfTest {
+		if err = runSelfTest(); err != nil {
+			return nil, "", fmt.Errorf("FAIL: %v", err)
+		}
+		fmt.Println("OK")
+		return nil, "", nil
+	}
+	if *completion != "" {
+		if *completion != "bash" {
+			return nil, "", fmt.Errorf("unsupported completion shell %q", *completion)
+		}
+		fmt.Print(bashCompletion(flags))
+		return nil, "", nil
+	}
+	if *initProjectFile {
+		return nil, "", initProject(defaultOptions())
+	}
+	if *profile != "" {
+		if err = applyOutputProfile(flags, *profile); err != nil {
+			return nil, "", err
+		}
+	}
+	if *config != "" {
+		if err = loadConfig(*config, opts); err != nil {
+			return nil, "", err
+		}
+	}
+	if flags.NArg() > 0 && isProjectFile(flags.Arg(0)) {
+		if opts.Project, err = loadProject(flags.Arg(0), opts); err != nil {
+			return nil, "", err
+		}
+	}
+	if *profile != "" || *config != "" || opts.Project != nil {
+		// apply explicit flags once more on top of profile and config
+		if err = flags.Parse(args); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = opts.resolve(); err != nil {
+		return nil, "", err
+	}
+	if opts.FromVideo != "" {
+		if flags.NArg() > 0 {
+			return nil, "", errors.New("DIR cannot be combined with -from-video")
+		}
+		return opts, "", nil
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return nil, "", nil
+	}
+	return opts, flags.Arg(0), nil
+}
+
+// Validate options and compute values derived from them
+func (opts *Options) resolve() error {
+	var err error
+	opts.Formats = effectiveFormats(parseExtensions(opts.IncludeExtension),
+		parseExtensions(opts.ExcludeExtension))
+	if len(opts.Formats) < 1 {
+		return errors.New("all extensions are excluded")
+	}
+	if opts.ForceType != "" {
+		if opts.ForceType, err = parseForceType(opts.ForceType); err != nil {
+			return err
+		}
+		// every file is an image now
+		opts.Formats = nil
+	}
+	if opts.OutputDpi < 0 {
+		return errors.New("output-dpi must not be negative")
+	}
+	if opts.Template, err = parsePageSize(opts.PageSize); err != nil {
+		return err
+	}
+	if opts.TrimTolerance > 255 {
+		return errors.New("trim-tolerance must be within 0-255")
+	}
+	if opts.PngCompression < 0 || opts.PngCompression > 9 {
+		return errors.New("png-compression must be within 1-9")
+	}
+	if strings.ContainsAny(opts.RenameOutputSuffix, `/\`) {
+		return errors.New("rename-output-suffix must not contain path separators")
+	}
+	if strings.ContainsAny(opts.OutputBasename, `/\`) {
+		return errors.New("output-basename must not contain path separators, use -output for full path")
+	}
+	if opts.OutputNameTemplate != "" {
+		if opts.NameTemplate, err = parseOutputNameTemplate(opts.OutputNameTemplate); err != nil {
+			return fmt.Errorf("invalid output-name-template: %v", err)
+		}
+	}
+	if opts.ZoomPercent, err = parseZoom(opts.InitialZoom); err != nil {
+		return err
+	}
+	if _, ok := pageLayouts[opts.PageLayout]; !ok && opts.PageLayout != "" {
+		return fmt.Errorf("unknown page-layout %q", opts.PageLayout)
+	}
+	if opts.SortXattr, err = parseSort(opts.Sort); err != nil {
+		return err
+	}
+	if opts.FirstN < 0 || opts.LastN < 0 {
+		return errors.New("first-n and last-n must not be negative")
+	}
+	if opts.MinImageCount < 0 || opts.MaxImageCount < 0 {
+		return errors.New("image count bounds must not be negative")
+	}
+	if opts.MaxImageCount > 0 && opts.MaxImageCount < opts.MinImageCount {
+		return errors.New("max-image-count must not be below min-image-count")
+	}
+	if opts.OrderFileFuzzyThreshold < 0 {
+		return errors.New("order-file-fuzzy-threshold must not be negative")
+	}
+	opts.Temp = &tempFiles{parent: opts.TempDir, keep: opts.KeepTemp || debugTemp}
+	opts.Warn = printWarning
+	if opts.StrictMode {
+		opts.Warn = failOnWarning
+	}
+	switch opts.OutputFormat {
+	case "pdf", "docx", "epub":
+	default:
+		return fmt.Errorf("unknown output-format %q, expected pdf, docx or epub", opts.OutputFormat)
+	}
+	if opts.MergePdf != "" {
+		if opts.splits() || opts.OutputBase64 || opts.OutputFormat != "pdf" {
+			return errors.New("merge-pdf cannot be combined with splitting, output-base64 or output-format other than pdf")
+		}
+		if err = checkMergePdf(opts.MergePdf); err != nil {
+			return err
+		}
+	}
+	if err = validTiffCompression(opts.TiffCompression); err != nil {
+		return err
+	}
+	if opts.SinglePageGap < 0 {
+		return errors.New("single-page-gap must not be negative")
+	}
+	if opts.SplitOnChange != "" && !validSplitProperty(opts.SplitOnChange) {
+		return fmt.Errorf("unknown split-on-change %q, expected one of %s", opts.SplitOnChange, strings.Join(splitProperties, ", "))
+	}
+	if opts.SinglePage && (opts.splits() || opts.OutputFormat != "pdf") {
+		return errors.New("single-page cannot be combined with splitting or output-format other than pdf")
+	}
+	// single page is written without page decorations and png text chunks
+	if opts.SinglePage && (opts.PageNumbers || opts.SummaryPage || opts.ChapterSeparator != "" || opts.UsePngMetadata) {
+		return errors.New("single-page cannot be combined with page-numbers, summary-page, chapter-separator or use-png-metadata")
+	}
+	if opts.SummaryCols < 1 {
+		return errors.New("summary-cols must be at least 1")
+	}
+	if opts.SummaryThumbHeight <= 0 {
+		return errors.New("summary-thumb-height must be positive")
+	}
+	// merged pages would shift rendered page numbers
+	if (opts.SummaryPage || opts.PageNumbers) && opts.MergePdf != "" {
+		return errors.New("summary-page and page-numbers cannot be combined with merge-pdf")
+	}
+	if opts.OnePdfPerImage && (opts.SinglePage || opts.splits() || opts.OutputFormat != "pdf" || opts.Incremental) {
+		return errors.New("one-pdf-per-image cannot be combined with single-page, splitting, incremental or output-format other than pdf")
+	}
+	// every pdf is named after its image
+	if opts.OnePdfPerImage && (opts.OutputNameTemplate != "" || opts.OutputBasename != "") {
+		return errors.New("one-pdf-per-image cannot be combined with output-name-template or output-basename")
+	}
+	if opts.OutputBase64 && (opts.splits() || opts.PostProcessScript != "") {
+		return errors.New("output-base64 cannot be combined with splitting or post-process-script")
+	}
+	if opts.Base64LineLength < 0 {
+		return errors.New("base64-line-length must not be negative")
+	}
+	if opts.FromVideoFps <= 0 {
+		return errors.New("from-video-fps must be positive")
+	}
+	if opts.ImageGapAbove < 0 || opts.ImageGapBelow < 0 {
+		return errors.New("image gaps must not be negative")
+	}
+	if opts.OutputCompressionFilter != "flate" && opts.OutputCompressionFilter != "none" {
+		return fmt.Errorf("unknown output-compression-filter %q, expected flate or none", opts.OutputCompressionFilter)
+	}
+	if opts.Language != "" {
+		if opts.Language, err = parseLanguage(opts.Language); err != nil {
+			return err
+		}
+	}
+	if opts.NamedDestIndex != "" {
+		if opts.splits() {
+			return errors.New("named-dest-index cannot be combined with splitting")
+		}
+		opts.NamedDestinations = true
+	}
+	// mutool rewrites catalog and renumbers pages of merged document
+	if opts.MergePdf != "" && (opts.NamedDestinations || opts.ZoomPercent > 0 || opts.HideToolbar || opts.HideMenubar ||
+		opts.Language != "" || opts.DetectLanguage) {
+		return errors.New("merge-pdf cannot be combined with named-destinations, named-dest-index, initial-zoom, " +
+			"hide-toolbar, hide-menubar, language or detect-language")
+	}
+	if opts.ImageResolutionLevel != "" {
+		if opts.TiffAllFrames {
+			return errors.New("image-resolution-level cannot be combined with tiff-all-frames")
+		}
+		if opts.ResolutionLevel, err = parseResolutionLevel(opts.ImageResolutionLevel); err != nil {
+			return err
+		}
+	}
+	if opts.ImagePadding < 0 {
+		return errors.New("image-padding must not be negative")
+	}
+	if opts.PaddingColor, err = parseHexColor(opts.ImagePaddingColor); err != nil {
+		return err
+	}
+	if opts.PreviewPages < 0 {
+		return errors.New("preview-pages must not be negative")
+	}
+	if opts.NormalizeDimensions != "" {
+		size, err := parsePixelSize(opts.NormalizeDimensions)
+		if err != nil {
+			return fmt.Errorf("invalid normalize-dimensions: %v", err)
+		}
+		opts.Normalize = &size
+	}
+	if opts.PosterTile != "" {
+		grid, err := parsePosterTile(opts.PosterTile)
+		if err != nil {
+			return err
+		}
+		opts.Poster = &grid
+	}
+	if opts.SpineWidth < 0 {
+		return errors.New("spine-width must not be negative")
+	}
+	if opts.AspectRatio != "" {
+		if opts.Ratio, err = parseAspectRatio(opts.AspectRatio); err != nil {
+			return err
+		}
+	}
+	switch opts.ForceOrientation {
+	case "", "portrait", "landscape":
+	default:
+		return fmt.Errorf("unknown force-orientation %q, expected portrait or landscape", opts.ForceOrientation)
+	}
+	if opts.ForceOrientation != "" && (opts.Ratio > 0 || opts.SmartResize || opts.OutputDpi > 0) {
+		return errors.New("force-orientation cannot be combined with aspect-ratio, smart-resize or output-dpi")
+	}
+	if opts.OverlayImage != "" {
+		if err = checkOverlayImage(opts.OverlayImage); err != nil {
+			return err
+		}
+		if opts.OverlayW < 0 || opts.OverlayH < 0 || (opts.OverlayW == 0 && opts.OverlayH == 0) {
+			return errors.New("overlay-w and overlay-h must not be negative and at least one must be set")
+		}
+	}
+	if opts.Shadow < 0 || opts.ShadowBlur < 0 {
+		return errors.New("shadow and shadow-blur must not be negative")
+	}
+	if opts.ShadowRGB, err = parseHexColor(opts.ShadowColor); err != nil {
+		return err
+	}
+	if !validPageNumberPosition(opts.PageNumberPosition) {
+		return fmt.Errorf("unknown page-number-position %q", opts.PageNumberPosition)
+	}
+	if opts.ChapterTitleFontSize <= 0 {
+		return errors.New("chapter-title-font-size must be positive")
+	}
+	if !validCoreFont(opts.ChapterTitleFontFamily) {
+		return fmt.Errorf("unknown chapter-title-font-family %q", opts.ChapterTitleFontFamily)
+	}
+	if _, ok := chapterTitleAlignments[opts.ChapterTitleAlignment]; !ok {
+		return fmt.Errorf("unknown chapter-title-alignment %q", opts.ChapterTitleAlignment)
+	}
+	if _, ok := chapterTitlePositions[opts.ChapterTitleVerticalPosition]; !ok {
+		return fmt.Errorf("unknown chapter-title-vertical-position %q", opts.ChapterTitleVerticalPosition)
+	}
+	if opts.TextColor, err = parseHexColor(opts.FontColor); err != nil {
+		return err
+	}
+	if opts.RotationMap != "" {
+		if opts.Rotations, err = loadRotationMap(opts.RotationMap); err != nil {
+			return err
+		}
+	}
+	if opts.PreRotateByFilenamePattern != "" {
+		if opts.RotationPattern, err = parseRotationPattern(opts.PreRotateByFilenamePattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
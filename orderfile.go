@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Read names listed in order file, one per line, blank lines are skipped
+func readOrderFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// whitespace is kept, it may be part of name
+		if name := strings.TrimRight(scanner.Text(), "\r"); strings.TrimSpace(name) != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// Name of order file picked up from every input directory
+const dirOrderFile = ".order"
+
+// Reorder images of directory as listed in its .order file, if any.
+// Explicit -order-file takes precedence over discovered ones
+func applyDirOrderFile(dirpath string, paths []string, opts *Options) ([]string, error) {
+	if opts.IgnoreOrderFile || opts.OrderFile != "" {
+		return paths, nil
+	}
+	filename := filepath.Join(dirpath, dirOrderFile)
+	if _, err := os.Stat(filename); err != nil {
+		return paths, nil
+	}
+	names, err := readOrderFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "Using .order file for sorting:", filename)
+	chapters, err := applyOrderFile([]chapter{{paths: paths}}, names, opts)
+	if err != nil {
+		return nil, err
+	}
+	return chapters[0].paths, nil
+}
+
+// Reorder images of every chapter as listed in order file by base name.
+// Listed images go first, others follow in their sorted order.
+// With -order-file-fuzzy names without exact match are matched
+// to closest remaining image within threshold edits
+func applyOrderFile(chapters []chapter, names []string, opts *Options) ([]chapter, error) {
+	matched := make(map[string]bool)
+	for i, ch := range chapters {
+		position := make(map[string]int)
+		for rank, name := range names {
+			elem, ok, err := matchOrderName(ch.paths, position, name, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				position[elem] = rank
+				matched[name] = true
+			}
+		}
+		paths := append([]string{}, ch.paths...)
+		sort.SliceStable(paths, func(a, b int) bool {
+			rankA, listedA := position[paths[a]]
+			rankB, listedB := position[paths[b]]
+			if listedA && listedB {
+				return rankA < rankB
+			}
+			return listedA && !listedB
+		})
+		chapters[i].paths = paths
+	}
+	for _, name := range names {
+		if !matched[name] {
+			if err := opts.warn("no image matches order file entry %q", name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return chapters, nil
+}
+
+// Find image named as order file entry among ones not taken yet
+func matchOrderName(paths []string, taken map[string]int, name string, opts *Options) (string, bool, error) {
+	for _, elem := range paths {
+		if _, ok := taken[elem]; !ok && filepath.Base(elem) == name {
+			return elem, true, nil
+		}
+	}
+	if !opts.OrderFileFuzzy {
+		return "", false, nil
+	}
+	best, bestDistance := "", opts.OrderFileFuzzyThreshold+1
+	for _, elem := range paths {
+		if _, ok := taken[elem]; ok {
+			continue
+		}
+		distance := levenshtein(normalizeOrderName(filepath.Base(elem)), normalizeOrderName(name))
+		if distance < bestDistance {
+			best, bestDistance = elem, distance
+		}
+	}
+	if best == "" {
+		return "", false, nil
+	}
+	err := opts.warn("order file entry %q fuzzy matched %s", name, filepath.Base(best))
+	return best, true, err
+}
+
+// Ignore case and surrounding whitespace when matching fuzzily
+func normalizeOrderName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Compute edit distance between strings counted in runes
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Get smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
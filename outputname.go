@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// Variables available in -output-name-template
+type outputNameData struct {
+	// base name and absolute path of converted directory
+	DirName string
+	AbsDir  string
+	// time of conversion as YYYYMMDD and HHMMSS
+	Date string
+	Time string
+	// number of images and of pages in resulting pdf
+	Count     int
+	PageCount int
+}
+
+// Collect template variables known before conversion,
+// PageCount is filled in when pdf is written
+func newOutputNameData(dir string, count int) *outputNameData {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	return &outputNameData{
+		DirName: filepath.Base(absDir),
+		AbsDir:  absDir,
+		Date:    now.Format("20060102"),
+		Time:    now.Format("150405"),
+		Count:   count,
+	}
+}
+
+// Parse and trial run output name template, so that
+// unknown variables are reported before conversion
+func parseOutputNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output-name-template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err = tmpl.Execute(&bytes.Buffer{}, outputNameData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Replace base name of saveAs with expanded -output-name-template,
+// saveAs is returned unchanged when template is not in use
+func expandOutputName(saveAs string, opts *Options, pageCount int) string {
+	if opts.OutputNames == nil {
+		return saveAs
+	}
+	data := *opts.OutputNames
+	data.PageCount = pageCount
+	var buf bytes.Buffer
+	if err := opts.NameTemplate.Execute(&buf, data); err != nil {
+		panic(err)
+	}
+	name := filepath.Clean(buf.String())
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(saveAs), name)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"os"
+)
+
+// Place overlay image over content of current page, zero
+// width or height is derived from aspect ratio of overlay
+func drawOverlay(document *gofpdf.Fpdf, opts *Options) {
+	// -force-type applies to input images only
+	options := gofpdf.ImageOptions{ImageType: registeredFormats[imageType(opts.OverlayImage)].ImageType}
+	document.ImageOptions(opts.OverlayImage, opts.OverlayX, opts.OverlayY, opts.OverlayW, opts.OverlayH, false, options, 0, "")
+}
+
+// Check that overlay image can be embedded as is
+func checkOverlayImage(imagepath string) error {
+	if _, err := os.Stat(imagepath); err != nil {
+		return err
+	}
+	if format := imageType(imagepath); !embeddable(format) {
+		return fmt.Errorf("overlay-image must be png, jpeg or gif, got %s", imagepath)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/jung-kurt/gofpdf"
+	"strconv"
+	"strings"
+)
+
+const (
+	pageNumberSize   = 10
+	pageNumberMargin = 10
+)
+
+// Supported positions of page number
+var pageNumberPositions = []string{
+	"top-left", "top-center", "top-right",
+	"bottom-left", "bottom-center", "bottom-right",
+}
+
+// Check if position is one of supported ones
+func validPageNumberPosition(position string) bool {
+	for _, elem := range pageNumberPositions {
+		if elem == position {
+			return true
+		}
+	}
+	return false
+}
+
+// Compute baseline start of text with given size on page of size w x h.
+// Position has form vertical-horizontal, e.g. bottom-center
+func pageNumberXY(position string, w, h, textW, textH float64) (x, y float64) {
+	switch {
+	case strings.HasSuffix(position, "-left"):
+		x = pageNumberMargin
+	case strings.HasSuffix(position, "-right"):
+		x = w - pageNumberMargin - textW
+	default:
+		x = (w - textW) / 2
+	}
+	if strings.HasPrefix(position, "top-") {
+		y = pageNumberMargin + textH
+	} else {
+		y = h - pageNumberMargin
+	}
+	return x, y
+}
+
+// Render number of every page at position when it is finished
+func setupPageNumbers(document *gofpdf.Fpdf, font textFont, opts *Options, pages pageLog) {
+	document.SetFooterFunc(func() {
+		text := strconv.Itoa(document.PageNo())
+		document.SetFont(font.family, "", pageNumberSize)
+		_, textH := document.GetFontSize()
+		textW := document.GetStringWidth(text)
+		w, h := document.GetPageSize()
+		x, y := pageNumberXY(opts.PageNumberPosition, w, h, textW, textH)
+		setTextColor(document, opts, pages, x, y-textH, textW, textH)
+		document.Text(x, y, text)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"strconv"
+	"strings"
+)
+
+// Named page sizes in mm, page width follows width of template
+var pageSizes = map[string]gofpdf.SizeType{
+	"a3":      {Wd: 297, Ht: 420},
+	"a4":      {Wd: a4Width, Ht: a4Height},
+	"a5":      {Wd: 148, Ht: 210},
+	"letter":  {Wd: 215.9, Ht: 279.4},
+	"legal":   {Wd: 215.9, Ht: 355.6},
+	"tabloid": {Wd: 279.4, Ht: 431.8},
+}
+
+// Names of page sizes as displayed to user
+var pageSizeNames = []string{"A3", "A4", "A5", "letter", "legal", "tabloid"}
+
+// Parse page size given either by name or as WxH in mm, e.g. 100x150
+func parsePageSize(value string) (gofpdf.SizeType, error) {
+	if size, ok := pageSizes[strings.ToLower(value)]; ok {
+		return size, nil
+	}
+	parts := strings.Split(strings.ToLower(value), "x")
+	if len(parts) == 2 {
+		w, errW := strconv.ParseFloat(parts[0], 64)
+		h, errH := strconv.ParseFloat(parts[1], 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return gofpdf.SizeType{Wd: w, Ht: h}, nil
+		}
+	}
+	return gofpdf.SizeType{}, fmt.Errorf("unknown page-size %q, expected one of %s or WxH",
+		value, strings.Join(pageSizeNames, ", "))
+}
+
+// Size of image in pixels
+type pixelSize struct {
+	w, h int
+}
+
+// Parse pixel size given as WxH, e.g. 2480x3508
+func parsePixelSize(value string) (pixelSize, error) {
+	parts := strings.Split(strings.ToLower(value), "x")
+	if len(parts) == 2 {
+		w, errW := strconv.Atoi(parts[0])
+		h, errH := strconv.Atoi(parts[1])
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return pixelSize{w, h}, nil
+		}
+	}
+	return pixelSize{}, fmt.Errorf("expected WxH in pixels, got %q", value)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Write every image into its own single page pdf named after it,
+// e.g. img001.jpg to img001.pdf, placed into directory of saveAs
+func writeImagePdfs(chapters []chapter, saveAs string, opts *Options) error {
+	if err := checkImageCount(countImages(chapters), opts); err != nil {
+		return err
+	}
+	outDir := filepath.Dir(saveAs)
+	texts := renderedTexts(chapters, opts)
+	// images of different chapters may share name
+	sources := make(map[string]string)
+	for _, elem := range chapterPaths(chapters) {
+		name := filepath.Base(elem)
+		filename := filepath.Join(outDir, strings.TrimSuffix(name, filepath.Ext(name))+opts.RenameOutputSuffix+".pdf")
+		if previous, ok := sources[filename]; ok {
+			return fmt.Errorf("one-pdf-per-image would write both %s and %s into %s", previous, elem, filename)
+		}
+		sources[filename] = elem
+		pdf, _, pages, err := startDocument(elem, texts, opts)
+		if err != nil {
+			return err
+		}
+		if _, err = addImagePage(pdf, elem, false, opts, pages); err != nil {
+			return err
+		}
+		// image was skipped, e.g. as blank page
+		if pdf.PageNo() == 0 {
+			continue
+		}
+		if err = writeDocument(pdf, filename, opts, pages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// Rewrite png with image data compressed at zlib level 1-9.
+// Pixel data and filters stay the same, so conversion is lossless.
+// IDAT chunks are merged into one, other chunks are kept as is
+func recompressPng(data []byte, level int) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, errors.New("not a png file")
+	}
+	var compressed, before, after bytes.Buffer
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("png chunk is truncated")
+		}
+		chunk := data[pos:end]
+		switch {
+		case string(chunk[4:8]) == "IDAT":
+			compressed.Write(chunk[8 : 8+length])
+		case compressed.Len() == 0:
+			before.Write(chunk)
+		default:
+			after.Write(chunk)
+		}
+		pos = end
+	}
+	reader, err := zlib.NewReader(&compressed)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var idat bytes.Buffer
+	writer, err := zlib.NewWriterLevel(&idat, level)
+	if err != nil {
+		return nil, err
+	}
+	writer.Write(raw)
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	var result bytes.Buffer
+	result.WriteString(pngSignature)
+	result.Write(before.Bytes())
+	writePngChunk(&result, "IDAT", idat.Bytes())
+	result.Write(after.Bytes())
+	return result.Bytes(), nil
+}
+
+// Call visit for every png chunk until it returns false
+func walkPngChunks(data []byte, visit func(chunkType string, chunk []byte) bool) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return
+	}
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return
+		}
+		if !visit(string(data[pos+4:pos+8]), data[pos+8:pos+8+length]) {
+			return
+		}
+		pos = end
+	}
+}
+
+// Get data of first chunk of given type, nil when there is none
+func pngChunk(data []byte, chunkType string) []byte {
+	var result []byte
+	walkPngChunks(data, func(t string, chunk []byte) bool {
+		if t == chunkType {
+			result = chunk
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+// Write png chunk with length and checksum
+func writePngChunk(buf *bytes.Buffer, chunkType string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], chunkType)
+	buf.Write(header[:])
+	buf.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
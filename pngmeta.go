@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/jung-kurt/gofpdf"
+	"io/ioutil"
+)
+
+// Get textual metadata of png from tEXt and iTXt chunks by keyword,
+// e.g. Title, Author, Description. First value of keyword wins
+func pngText(data []byte) map[string]string {
+	result := make(map[string]string)
+	walkPngChunks(data, func(chunkType string, chunk []byte) bool {
+		var keyword, text string
+		var ok bool
+		switch chunkType {
+		case "tEXt":
+			keyword, text, ok = parseTextChunk(chunk)
+		case "iTXt":
+			keyword, text, ok = parseITextChunk(chunk)
+		}
+		if _, seen := result[keyword]; ok && !seen {
+			result[keyword] = text
+		}
+		return true
+	})
+	return result
+}
+
+// Parse tEXt chunk: keyword, zero byte and latin-1 text
+func parseTextChunk(chunk []byte) (keyword, text string, ok bool) {
+	sep := bytes.IndexByte(chunk, 0)
+	if sep < 1 {
+		return "", "", false
+	}
+	runes := make([]rune, 0, len(chunk)-sep-1)
+	for _, b := range chunk[sep+1:] {
+		runes = append(runes, rune(b))
+	}
+	return string(chunk[:sep]), string(runes), true
+}
+
+// Parse iTXt chunk: keyword, zero byte, compression flag and method,
+// language tag and translated keyword both ending with zero byte,
+// then utf-8 text, zlib compressed when flag is set
+func parseITextChunk(chunk []byte) (keyword, text string, ok bool) {
+	sep := bytes.IndexByte(chunk, 0)
+	if sep < 1 || sep+3 > len(chunk) {
+		return "", "", false
+	}
+	compressed := chunk[sep+1] == 1
+	rest := chunk[sep+3:]
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return "", "", false
+		}
+		rest = rest[end+1:]
+	}
+	if compressed {
+		reader, err := zlib.NewReader(bytes.NewReader(rest))
+		if err != nil {
+			return "", "", false
+		}
+		if rest, err = ioutil.ReadAll(reader); err != nil {
+			return "", "", false
+		}
+	}
+	return string(chunk[:sep]), string(rest), true
+}
+
+// Get text metadata of image, empty for other formats than png
+func imageText(imagepath string, opts *Options) map[string]string {
+	if opts.imageType(imagepath) != "png" {
+		return nil
+	}
+	return pngText(readFile(imagepath))
+}
+
+// Fill document properties from metadata of its first image,
+// Description becomes subject and Comment is used in its absence
+func setPngMetadata(document *gofpdf.Fpdf, imagepath string, opts *Options) {
+	text := imageText(imagepath, opts)
+	if title := text["Title"]; title != "" {
+		document.SetTitle(title, true)
+	}
+	if author := text["Author"]; author != "" {
+		document.SetAuthor(author, true)
+	}
+	if subject := text["Description"]; subject != "" {
+		document.SetSubject(subject, true)
+	} else if comment := text["Comment"]; comment != "" {
+		document.SetSubject(comment, true)
+	}
+}
+
+// Add bookmark to current page titled after image metadata, if any
+func addPngBookmark(document *gofpdf.Fpdf, font textFont, imagepath string, opts *Options) {
+	title := imageText(imagepath, opts)["Title"]
+	if title == "" {
+		return
+	}
+	// current font decides how gofpdf encodes bookmark text
+	document.SetFont(font.family, font.style, 0)
+	document.Bookmark(font.encode(document, title), 0, 0)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Grid of pages one image is split into
+type posterGrid struct {
+	cols, rows int
+}
+
+// Parse grid of poster tiles given as NxM, N columns by M rows
+func parsePosterTile(value string) (posterGrid, error) {
+	parts := strings.Split(strings.ToLower(value), "x")
+	if len(parts) == 2 {
+		cols, errCols := strconv.Atoi(parts[0])
+		rows, errRows := strconv.Atoi(parts[1])
+		if errCols == nil && errRows == nil && cols > 0 && rows > 0 {
+			return posterGrid{cols, rows}, nil
+		}
+	}
+	return posterGrid{}, fmt.Errorf("invalid poster-tile %q, expected NxM, e.g. 2x3", value)
+}
+
+// Get bounds of tile at column and row, tiles cover image without gaps
+func (grid posterGrid) tile(bounds image.Rectangle, col, row int) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	return image.Rect(
+		bounds.Min.X+col*w/grid.cols, bounds.Min.Y+row*h/grid.rows,
+		bounds.Min.X+(col+1)*w/grid.cols, bounds.Min.Y+(row+1)*h/grid.rows,
+	).Intersect(bounds)
+}
+
+// Split frame of image into tiles, each one added as page sized by it
+// like separate image. Tiles go row by row, rotation is applied before
+// splitting. Returns size of embedded image data in bytes
+func addPosterPages(document *gofpdf.Fpdf, imagepath string, frame int, opts *Options, pages pageLog) (int64, error) {
+	if skip, err := skipBlankFrame(imagepath, frame, opts); skip || err != nil {
+		return 0, err
+	}
+	img := decodeFrame(imagepath, frame)
+	if opts.TrimWhitespace {
+		img = cropImage(img, trimWhitespace(img, uint8(opts.TrimTolerance)))
+	}
+	img = rotateImage(img, imageRotation(imagepath, opts))
+	grid := *opts.Poster
+	var size int64
+	for row := 0; row < grid.rows; row++ {
+		for col := 0; col < grid.cols; col++ {
+			tile := cropImage(img, grid.tile(img.Bounds(), col, row))
+			name := fmt.Sprintf("%s#%d@%d,%d", imagepath, frame, col, row)
+			tileSize := registerImage(document, name, tile, opts.PngCompression)
+			tileImage := pageImage{
+				name:      name,
+				imageType: "PNG",
+				w:         float64(tile.Bounds().Dx()),
+				h:         float64(tile.Bounds().Dy()),
+				size:      tileSize,
+				decoded:   tile,
+			}
+			resW, resH := opts.pageSize(tileImage.w, tileImage.h)
+			document.AddPageFormat("P", gofpdf.SizeType{Wd: resW, Ht: resH})
+			x, y, w, h := paddedRect(0, 0, resW, resH, opts.ImagePadding)
+			x, y, w, h = spineRect(document.PageNo(), x, y, w, h, opts.SpineWidth)
+			placeImage(document, tileImage, x, y, w, h, 0)
+			if opts.OverlayImage != "" {
+				drawOverlay(document, opts)
+			}
+			if err := checkPdfState(document, "adding poster tile of "+imagepath); err != nil {
+				return 0, err
+			}
+			pages[document.PageNo()] = append(pages[document.PageNo()], pageRecord{
+				imagepath: imagepath,
+				frame:     frame,
+				decoded:   tile,
+				x:         x,
+				y:         y,
+				w:         w,
+				h:         h,
+			})
+			size += tileSize
+		}
+	}
+	return size, nil
+}
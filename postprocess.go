@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Run user command through shell with path of written pdf as its
+// last argument. Failure of command is returned wrapping its
+// *exec.ExitError, so that exit code can be propagated
+func runPostProcess(script, pdfPath string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", script+` "`+pdfPath+`"`)
+	} else {
+		// path is passed as positional parameter, so it needs no quoting
+		cmd = exec.Command("sh", "-c", script+` "$@"`, "sh", pdfPath)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-process script failed on %s: %w", pdfPath, err)
+	}
+	return nil
+}
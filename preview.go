@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// reduced quality of preview images
+	previewJpegQuality = 50
+	previewMaxWidth    = 800
+)
+
+// Get path of preview pdf written next to saveAs
+func previewFilename(saveAs string) string {
+	return strings.TrimSuffix(saveAs, ".pdf") + ".preview.pdf"
+}
+
+// Write quick low quality pdf of first images before full conversion.
+// Side outputs such as split parts and post-processing are skipped
+func writePreview(chapters []chapter, saveAs string, opts *Options) error {
+	// limits apply to full list, not to images of preview
+	if err := checkImageCount(countImages(chapters), opts); err != nil {
+		return err
+	}
+	count := opts.PreviewPages
+	if total := countImages(chapters); count > total {
+		count = total
+	}
+	chapters, err := limitImages(chapters, count, 0)
+	if err != nil {
+		return err
+	}
+	previewOpts := *opts
+	previewOpts.Preview = true
+	previewOpts.Cache = nil
+	previewOpts.SplitBySizeMB = 0
+	previewOpts.SplitOnChange = ""
+	previewOpts.OutputBase64 = false
+	previewOpts.NamedDestIndex = ""
+	previewOpts.PostProcessScript = ""
+	previewOpts.OutputNames = nil
+	previewOpts.MinImageCount = 0
+	previewOpts.MaxImageCount = 0
+	saveAs = previewFilename(saveAs)
+	fmt.Fprintln(os.Stderr, "Writing preview:", saveAs)
+	return processChapters(chapters, saveAs, &previewOpts)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Presets of -output-profile as flag values, explicit flags
+// and config take precedence over them
+var outputProfiles = map[string]map[string]string{
+	// lossless pages at scan resolution with fonts embedded for PDF/A
+	"archive": {
+		"output-dpi":       "300",
+		"embed-full-fonts": "true",
+		"deterministic":    "true",
+	},
+	"web": {
+		"optimize-for-screen": "true",
+		"png-compression":     "9",
+		"strip-metadata":      "true",
+	},
+	"print": {
+		"output-dpi": "600",
+	},
+	// 6 inch e-reader screen
+	"ebook": {
+		"page-size":           "90x122",
+		"optimize-for-screen": "true",
+	},
+	"presentation": {
+		"page-size":    "254x142.875",
+		"aspect-ratio": "16:9",
+	},
+}
+
+// Names of output profiles as displayed to user
+var outputProfileNames = []string{"archive", "web", "print", "ebook", "presentation"}
+
+// Set flags to values of output profile
+func applyOutputProfile(flags *flag.FlagSet, profile string) error {
+	values, ok := outputProfiles[profile]
+	if !ok {
+		return fmt.Errorf("unknown output-profile %q, expected one of %s", profile, strings.Join(outputProfileNames, ", "))
+	}
+	for name, value := range values {
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
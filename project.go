@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extension of project files, see loadProject
+const projectExt = ".imgdir2pdf"
+
+// Name of project file created by -init
+const projectTemplateName = "project" + projectExt
+
+// Conversion job stored in project file
+type project struct {
+	Version int
+	// directories converted one after another into single pdf,
+	// relative ones are resolved against project file directory
+	Inputs []string
+	// shell patterns of file names to convert, all images when empty
+	Patterns []string
+	// options as in config file
+	Options map[string]interface{}
+	// path of project file
+	path string
+}
+
+// Check if argument names project file rather than directory
+func isProjectFile(arg string) bool {
+	stat, err := os.Stat(arg)
+	return err == nil && !stat.IsDir() && strings.HasSuffix(arg, projectExt)
+}
+
+// Load project file, its options are applied to opts like config.
+// Relative output path is resolved against project directory
+func loadProject(filename string, opts *Options) (*project, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	proj := &project{Version: 1, path: filename}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(proj); err != nil {
+		return nil, fmt.Errorf("invalid project %s: %v", filename, err)
+	}
+	if proj.Version != configVersion {
+		return nil, fmt.Errorf("project %s has version %d, expected %d",
+			filename, proj.Version, configVersion)
+	}
+	if len(proj.Inputs) < 1 {
+		return nil, fmt.Errorf("project %s has no inputs", filename)
+	}
+	for _, pattern := range proj.Patterns {
+		if _, err = filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in project %s", pattern, filename)
+		}
+	}
+	if err = decodeRawConfig(proj.Options, opts); err != nil {
+		return nil, fmt.Errorf("invalid project %s: %v", filename, err)
+	}
+	base := filepath.Dir(filename)
+	if opts.Output != "" && !filepath.IsAbs(opts.Output) {
+		opts.Output = filepath.Join(base, opts.Output)
+	}
+	for i, input := range proj.Inputs {
+		if !filepath.IsAbs(input) {
+			proj.Inputs[i] = filepath.Join(base, input)
+		}
+	}
+	return proj, nil
+}
+
+// Collect chapters of all inputs. With several inputs each one is
+// named by its directory and nested chapters are prefixed with it
+func (proj *project) chapters(opts *Options) ([]chapter, error) {
+	var result []chapter
+	for _, input := range proj.Inputs {
+		chapters, err := collectChapters(input, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range chapters {
+			if len(proj.Inputs) > 1 {
+				ch.name = strings.TrimSuffix(filepath.Base(input)+"/"+ch.name, "/")
+			}
+			ch.paths = proj.filter(ch.paths)
+			if len(ch.paths) > 0 {
+				result = append(result, ch)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Keep paths whose base name matches any of project patterns
+func (proj *project) filter(paths []string) []string {
+	if len(proj.Patterns) < 1 {
+		return paths
+	}
+	var result []string
+	for _, elem := range paths {
+		for _, pattern := range proj.Patterns {
+			if matched, _ := filepath.Match(pattern, filepath.Base(elem)); matched {
+				result = append(result, elem)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Write project template with all options at their
+// defaults into current directory
+func initProject(defaults *Options) error {
+	if _, err := os.Stat(projectTemplateName); err == nil {
+		return errors.New(projectTemplateName + " already exists")
+	}
+	data, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	proj := project{Version: configVersion, Inputs: []string{"."}, Patterns: []string{}}
+	if err = json.Unmarshal(data, &proj.Options); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(proj, "", "  "); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(projectTemplateName, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Created", projectTemplateName)
+	return nil
+}
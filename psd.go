@@ -0,0 +1,214 @@
+//go:build psd
+// +build psd
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+const psdSignature = "8BPS"
+
+// Color modes of psd supported for merged image
+const (
+	psdGrayscale = 1
+	psdRGB       = 3
+	psdCMYK      = 4
+)
+
+func init() {
+	image.RegisterFormat("psd", psdSignature, decodePsd, decodePsdConfig)
+	imageSignatures = append(imageSignatures, imageSignature{"psd", psdSignature})
+	RegisterFormat("psd", FormatHandler{})
+}
+
+// Fixed size header of psd file
+type psdHeader struct {
+	Signature [4]byte
+	Version   uint16
+	Reserved  [6]byte
+	Channels  uint16
+	Height    uint32
+	Width     uint32
+	Depth     uint16
+	ColorMode uint16
+}
+
+// Read and validate psd header
+func readPsdHeader(reader io.Reader) (psdHeader, error) {
+	var header psdHeader
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return header, err
+	}
+	if string(header.Signature[:]) != psdSignature {
+		return header, errors.New("not a psd file")
+	}
+	if header.Version != 1 {
+		return header, errors.New("large document format psb is not supported")
+	}
+	if header.Depth != 8 && header.Depth != 16 {
+		return header, fmt.Errorf("psd with %d bits per channel is not supported", header.Depth)
+	}
+	switch header.ColorMode {
+	case psdGrayscale:
+	case psdRGB, psdCMYK:
+		if int(header.Channels) < psdModeChannels(header.ColorMode) {
+			return header, errors.New("psd has too few channels for its color mode")
+		}
+	default:
+		return header, fmt.Errorf("psd color mode %d is not supported", header.ColorMode)
+	}
+	return header, nil
+}
+
+// Get number of color channels of mode, others are alpha or spot colors
+func psdModeChannels(mode uint16) int {
+	switch mode {
+	case psdRGB:
+		return 3
+	case psdCMYK:
+		return 4
+	}
+	return 1
+}
+
+// Decode size and color model of psd
+func decodePsdConfig(reader io.Reader) (image.Config, error) {
+	header, err := readPsdHeader(reader)
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.RGBAModel
+	switch header.ColorMode {
+	case psdGrayscale:
+		model = color.GrayModel
+	case psdCMYK:
+		model = color.CMYKModel
+	}
+	return image.Config{ColorModel: model, Width: int(header.Width), Height: int(header.Height)}, nil
+}
+
+// Decode merged composite image which Photoshop stores after
+// layers, planar channel by channel, raw or PackBits compressed
+func decodePsd(r io.Reader) (image.Image, error) {
+	reader := bufio.NewReader(r)
+	header, err := readPsdHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	// color mode data, image resources, layer and mask information
+	for i := 0; i < 3; i++ {
+		var length uint32
+		if err = binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if _, err = io.CopyN(ioutil.Discard, reader, int64(length)); err != nil {
+			return nil, err
+		}
+	}
+	var compression uint16
+	if err = binary.Read(reader, binary.BigEndian, &compression); err != nil {
+		return nil, err
+	}
+	w, h := int(header.Width), int(header.Height)
+	rowBytes := w * int(header.Depth) / 8
+	channels := psdModeChannels(header.ColorMode)
+	planes := make([][]byte, channels)
+	switch compression {
+	case 0:
+		for c := range planes {
+			planes[c] = make([]byte, rowBytes*h)
+			if _, err = io.ReadFull(reader, planes[c]); err != nil {
+				return nil, err
+			}
+		}
+	case 1:
+		// byte counts of all rows of all channels precede the data
+		counts := make([]uint16, int(header.Channels)*h)
+		if err = binary.Read(reader, binary.BigEndian, counts); err != nil {
+			return nil, err
+		}
+		for c := range planes {
+			planes[c] = make([]byte, 0, rowBytes*h)
+			for y := 0; y < h; y++ {
+				packed := make([]byte, counts[c*h+y])
+				if _, err = io.ReadFull(reader, packed); err != nil {
+					return nil, err
+				}
+				row, err := unpackBits(packed, rowBytes)
+				if err != nil {
+					return nil, err
+				}
+				planes[c] = append(planes[c], row...)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("psd compression %d is not supported", compression)
+	}
+	// only high byte of 16-bit samples is kept
+	step := int(header.Depth) / 8
+	sample := func(c, i int) uint8 { return planes[c][i*step] }
+	bounds := image.Rect(0, 0, w, h)
+	switch header.ColorMode {
+	case psdGrayscale:
+		img := image.NewGray(bounds)
+		for i := range img.Pix {
+			img.Pix[i] = sample(0, i)
+		}
+		return img, nil
+	case psdCMYK:
+		// psd stores cmyk inverted, 255 is no ink
+		img := image.NewCMYK(bounds)
+		for i := 0; i < w*h; i++ {
+			for c := 0; c < 4; c++ {
+				img.Pix[i*4+c] = 255 - sample(c, i)
+			}
+		}
+		return img, nil
+	}
+	img := image.NewRGBA(bounds)
+	for i := 0; i < w*h; i++ {
+		img.Pix[i*4] = sample(0, i)
+		img.Pix[i*4+1] = sample(1, i)
+		img.Pix[i*4+2] = sample(2, i)
+		img.Pix[i*4+3] = 255
+	}
+	return img, nil
+}
+
+// Expand PackBits compressed row of n bytes
+func unpackBits(packed []byte, n int) ([]byte, error) {
+	row := make([]byte, 0, n)
+	for i := 0; i < len(packed) && len(row) < n; {
+		header := int(int8(packed[i]))
+		i++
+		switch {
+		case header >= 0:
+			end := i + header + 1
+			if end > len(packed) {
+				return nil, errors.New("psd row data is truncated")
+			}
+			row = append(row, packed[i:end]...)
+			i = end
+		case header > -128:
+			if i >= len(packed) {
+				return nil, errors.New("psd row data is truncated")
+			}
+			for j := 0; j < 1-header; j++ {
+				row = append(row, packed[i])
+			}
+			i++
+		}
+	}
+	if len(row) != n {
+		return nil, errors.New("psd row has unexpected length")
+	}
+	return row, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// Simple quality metrics of scanned image
+type imageQuality struct {
+	File string `json:"file"`
+	// variance of Laplacian, low for blurry images
+	Sharpness float64 `json:"sharpness"`
+	// standard deviation of difference from 3x3 neighbourhood mean
+	Noise float64 `json:"noise"`
+	// luminance range as fraction of full range
+	Contrast float64 `json:"contrast"`
+}
+
+// Get luminance of all pixels row by row in 0-255 range
+func luminances(img image.Image) (values []float64, w, h int) {
+	bounds := img.Bounds()
+	w, h = bounds.Dx(), bounds.Dy()
+	values = make([]float64, 0, w*h)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			values = append(values, float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y))
+		}
+	}
+	return values, w, h
+}
+
+// Compute quality metrics of image, sharpness and noise
+// are computed on pixels not touching image edge
+func measureQuality(img image.Image) imageQuality {
+	values, w, h := luminances(img)
+	var result imageQuality
+	if len(values) < 1 {
+		return result
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	result.Contrast = (high - low) / 255
+	var lapSum, lapSq, noiseSum, noiseSq, n float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			at := func(dx, dy int) float64 { return values[(y+dy)*w+x+dx] }
+			lap := at(-1, 0) + at(1, 0) + at(0, -1) + at(0, 1) - 4*at(0, 0)
+			lapSum += lap
+			lapSq += lap * lap
+			var box float64
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					box += at(dx, dy)
+				}
+			}
+			diff := at(0, 0) - box/9
+			noiseSum += diff
+			noiseSq += diff * diff
+			n++
+		}
+	}
+	if n > 0 {
+		result.Sharpness = lapSq/n - (lapSum/n)*(lapSum/n)
+		result.Noise = math.Sqrt(math.Max(noiseSq/n-(noiseSum/n)*(noiseSum/n), 0))
+	}
+	return result
+}
+
+// Measure quality of every image, printed as table to stdout
+// with -quality-report and written as json with -quality-report-file
+func qualityReport(chapters []chapter, opts *Options) error {
+	var report []imageQuality
+	for _, ch := range chapters {
+		for _, elem := range ch.paths {
+			quality := measureQuality(decodeImage(elem))
+			quality.File = elem
+			report = append(report, quality)
+		}
+	}
+	if opts.QualityReport {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "filename\tsharpness\tnoise\tcontrast")
+		for _, elem := range report {
+			fmt.Fprintf(writer, "%s\t%.1f\t%.2f\t%.2f\n",
+				filepath.Base(elem.File), elem.Sharpness, elem.Noise, elem.Contrast)
+		}
+		writer.Flush()
+	}
+	if opts.QualityReportFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(opts.QualityReportFile, append(data, '\n'), 0644)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Load rotations from json file mapping file names to
+// clockwise degrees, e.g. {"img001.jpg": 90, "img002.jpg": 270}
+func loadRotationMap(filename string) (map[string]int, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var rotations map[string]int
+	if err = json.Unmarshal(data, &rotations); err != nil {
+		return nil, fmt.Errorf("invalid rotation map %s: %v", filename, err)
+	}
+	for name, degrees := range rotations {
+		if degrees%90 != 0 {
+			return nil, fmt.Errorf("rotation of %s must be multiple of 90, got %d", name, degrees)
+		}
+		rotations[name] = (degrees%360 + 360) % 360
+	}
+	return rotations, nil
+}
+
+// Compile -pre-rotate-by-filename-pattern, which must match whole
+// file name and capture clockwise degrees in group named rotation
+func parseRotationPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pre-rotate-by-filename-pattern: %v", err)
+	}
+	if re.SubexpIndex("rotation") < 0 {
+		return nil, fmt.Errorf("pre-rotate-by-filename-pattern %q has no group named rotation, e.g. (?P<rotation>90|180|270)", pattern)
+	}
+	return re, nil
+}
+
+// Get rotation captured from file name by -pre-rotate-by-filename-pattern,
+// ok is false when name does not match or degrees are not multiple of 90
+func patternRotation(name string, re *regexp.Regexp) (degrees int, ok bool) {
+	match := re.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	degrees, err := strconv.Atoi(match[re.SubexpIndex("rotation")])
+	if err != nil || degrees%90 != 0 {
+		return 0, false
+	}
+	return (degrees%360 + 360) % 360, true
+}
+
+// Get clockwise rotation of image in degrees. Entries of -rotation-map
+// take precedence over -pre-rotate-by-filename-pattern. With
+// -auto-orient-landscape landscape images are turned by extra 90 degrees
+// on portrait template
+func imageRotation(imagepath string, opts *Options) int {
+	name := filepath.Base(imagepath)
+	degrees, ok := opts.Rotations[name]
+	if !ok && opts.RotationPattern != nil {
+		degrees, _ = patternRotation(name, opts.RotationPattern)
+	}
+	if opts.AutoOrientLandscape && opts.Template.Wd < opts.Template.Ht {
+		w, h := getImageSize(imagepath)
+		if swapsSides(degrees) {
+			w, h = h, w
+		}
+		if w > h {
+			degrees = (degrees + 90) % 360
+		}
+	}
+	return degrees
+}
+
+// Check if rotation swaps width and height
+func swapsSides(degrees int) bool {
+	return degrees == 90 || degrees == 270
+}
+
+// Place image into page area x, y, w, h rotated clockwise by degrees.
+// Area size is given after rotation
+func placeImage(document *gofpdf.Fpdf, img pageImage, x, y, w, h float64, degrees int) {
+	options := gofpdf.ImageOptions{ImageType: img.imageType, ReadDpi: true}
+	if degrees == 0 {
+		document.ImageOptions(img.name, x, y, w, h, false, options, 0, "")
+		return
+	}
+	drawW, drawH := w, h
+	if swapsSides(degrees) {
+		drawW, drawH = h, w
+	}
+	cx, cy := x+w/2, y+h/2
+	document.TransformBegin()
+	// gofpdf rotates counter-clockwise
+	document.TransformRotate(float64(-degrees), cx, cy)
+	document.ImageOptions(img.name, cx-drawW/2, cy-drawH/2, drawW, drawH, false, options, 0, "")
+	document.TransformEnd()
+}
+
+// Rotate pixels of image clockwise by multiple of 90 degrees
+func rotateImage(img image.Image, degrees int) image.Image {
+	if degrees == 0 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	if swapsSides(degrees) {
+		result = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			switch degrees {
+			case 90:
+				result.Set(h-1-y, x, c)
+			case 180:
+				result.Set(w-1-x, h-1-y, c)
+			case 270:
+				result.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return result
+}
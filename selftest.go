@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// Images converted by -test, one page each. The jfif one checks
+// that .jfif files are picked up and embedded as jpeg
+//
+//go:embed selftest/*.png selftest/*.jfif
+var selfTestImages embed.FS
+
+// Matches page objects, but not page tree nodes
+var pdfPageObject = regexp.MustCompile(`/Type /Page\b[^s]`)
+
+// Convert embedded images with default options into temporary pdf
+// and validate result, also with -deterministic, so binary can be
+// checked without images at hand
+func runSelfTest() error {
+	opts := defaultOptions()
+	if err := opts.resolve(); err != nil {
+		return err
+	}
+	defer opts.Temp.cleanup()
+	dir, err := opts.Temp.mkdir("selftest")
+	if err != nil {
+		return err
+	}
+	entries, err := selfTestImages.ReadDir("selftest")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		data, err := selfTestImages.ReadFile(path.Join("selftest", entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, entry.Name()), data, 0644); err != nil {
+			return err
+		}
+	}
+	chapters, err := collectChapters(dir, opts)
+	if err != nil {
+		return fmt.Errorf("collecting images: %v", err)
+	}
+	saveAs := filepath.Join(dir, "selftest.pdf")
+	if err = processChapters(chapters, saveAs, opts); err != nil {
+		return fmt.Errorf("converting images: %v", err)
+	}
+	data, err := ioutil.ReadFile(saveAs)
+	if err != nil {
+		return fmt.Errorf("reading result: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return fmt.Errorf("result does not start with pdf header, got %q", data[:minInt(len(data), 8)])
+	}
+	if pages := len(pdfPageObject.FindAll(data, -1)); pages != len(entries) {
+		return fmt.Errorf("result has %d pages, expected %d", pages, len(entries))
+	}
+	return checkDeterministic(chapters, dir, opts)
+}
+
+// Check that -deterministic gives identical files on repeated runs,
+// test images of equal width are ordered by map iteration otherwise
+func checkDeterministic(chapters []chapter, dir string, opts *Options) error {
+	const runs = 8
+	opts.Deterministic = true
+	var first []byte
+	for i := 0; i < runs; i++ {
+		saveAs := filepath.Join(dir, fmt.Sprintf("deterministic%d.pdf", i))
+		if err := processChapters(chapters, saveAs, opts); err != nil {
+			return fmt.Errorf("converting images deterministically: %v", err)
+		}
+		data, err := ioutil.ReadFile(saveAs)
+		if err != nil {
+			return fmt.Errorf("reading result: %v", err)
+		}
+		if i == 0 {
+			first = data
+		} else if !bytes.Equal(data, first) {
+			return fmt.Errorf("deterministic result of run %d differs from first one", i+1)
+		}
+	}
+	return nil
+}
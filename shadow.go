@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/jung-kurt/gofpdf"
+	"math"
+)
+
+// Distance in mm between edges of rectangles approximating blur
+const shadowBlurStep = 0.5
+
+// Draw drop shadow of image area x, y, w, h offset down and right.
+// Blur is approximated by semi-transparent rectangles growing
+// up to blur mm beyond shadow edges, which add up towards its center
+func drawShadow(document *gofpdf.Fpdf, x, y, w, h float64, opts *Options) {
+	c := opts.ShadowRGB
+	document.SetFillColor(c.r, c.g, c.b)
+	x, y = x+opts.Shadow, y+opts.Shadow
+	steps := int(math.Min(math.Ceil(opts.ShadowBlur/shadowBlurStep), 20))
+	for i := steps; i > 0; i-- {
+		grow := opts.ShadowBlur * float64(i) / float64(steps)
+		document.SetAlpha(1/float64(steps+1), "Normal")
+		document.Rect(x-grow, y-grow, w+2*grow, h+2*grow, "F")
+	}
+	document.SetAlpha(1, "Normal")
+	document.Rect(x, y, w, h, "F")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/jung-kurt/gofpdf"
+	"math"
+)
+
+// Largest page side in mm most viewers display, 14400 pt
+const maxPageSide = 5080
+
+// Stack images of all chapters into one tall page, separated
+// by gap in mm. Images narrower than widest one are centered
+func writeSinglePage(chapters []chapter, saveAs string, opts *Options) error {
+	if err := checkImageCount(countImages(chapters), opts); err != nil {
+		return err
+	}
+	paths := chapterPaths(chapters)
+	// page size is known only after all images are prepared
+	document := createDocument(opts.Template.Wd, opts.Template.Ht)
+	if opts.Deterministic {
+		makeDeterministic(document)
+	}
+	document.SetCompression(opts.OutputCompressionFilter == "flate")
+	setupViewer(document, opts)
+	// images and their sizes as placed on page after rotation
+	type placement struct {
+		img     pageImage
+		w, h    float64
+		degrees int
+	}
+	placements := make([]placement, len(paths))
+	var pageW, pageH float64
+	for i, elem := range paths {
+		img := prepareImage(document, elem, 0, opts)
+		if err := checkPdfState(document, "embedding "+elem); err != nil {
+			return err
+		}
+		// processed image may be trimmed or resized,
+		// -output-dpi sizes page by source pixels
+		w, h := img.sourceSize()
+		degrees := imageRotation(elem, opts)
+		if swapsSides(degrees) {
+			w, h = h, w
+		}
+		w, h = opts.pageSize(w, h)
+		placements[i] = placement{img, w, h, degrees}
+		pageW = math.Max(pageW, w)
+		pageH += h
+	}
+	pageH += opts.SinglePageGap * float64(len(paths)-1)
+	if pageH > maxPageSide {
+		if err := opts.warn("single page is %.0f mm tall, viewers may not display pages over %d mm", pageH, maxPageSide); err != nil {
+			return err
+		}
+	}
+	document.AddPageFormat("P", gofpdf.SizeType{Wd: pageW, Ht: pageH})
+	pages := make(pageLog)
+	y := 0.0
+	for i, elem := range paths {
+		p := placements[i]
+		x := (pageW - p.w) / 2
+		placeImage(document, p.img, x, y, p.w, p.h, p.degrees)
+		if err := checkPdfState(document, "placing "+elem); err != nil {
+			return err
+		}
+		pages[1] = append(pages[1], pageRecord{imagepath: elem, decoded: p.img.decoded, x: x, y: y, w: p.w, h: p.h, degrees: p.degrees})
+		y += p.h + opts.SinglePageGap
+	}
+	return writeDocument(document, saveAs, opts, pages)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Approximate size of pdf structures per page besides image data,
+// i.e. page and image objects, content stream and xref entries
+const pageOverheadBytes = 600
+
+// Accumulates estimated size of pdf being written
+type sizeEstimator struct {
+	limit int64
+	total int64
+}
+
+// Create estimator with limit in megabytes, 0 means no limit
+func newSizeEstimator(limitMB float64) sizeEstimator {
+	return sizeEstimator{limit: int64(limitMB * 1024 * 1024)}
+}
+
+// Account page with image data of given size.
+// Reports whether document has reached the limit
+func (e *sizeEstimator) addPage(imageBytes int64) bool {
+	e.total += imageBytes + pageOverheadBytes
+	return e.limit > 0 && e.total >= e.limit
+}
+
+// Get name of numbered part, e.g. dir.pdf turns into dir_2.pdf
+func partFilename(saveAs string, part int) string {
+	return fmt.Sprintf("%s_%d.pdf", strings.TrimSuffix(saveAs, ".pdf"), part)
+}
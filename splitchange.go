@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+)
+
+// Image properties whose change starts new pdf with -split-on-change
+var splitProperties = []string{"aspect-ratio", "color-space", "resolution"}
+
+// Check if property is one of supported ones
+func validSplitProperty(property string) bool {
+	for _, elem := range splitProperties {
+		if elem == property {
+			return true
+		}
+	}
+	return false
+}
+
+// Get value of property of image compared between consecutive images
+func imageProperty(imagepath, property string, opts *Options) string {
+	file, err := os.Open(imagepath)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		panic(err)
+	}
+	w, h := config.Width, config.Height
+	if swapsSides(imageRotation(imagepath, opts)) {
+		w, h = h, w
+	}
+	switch property {
+	case "aspect-ratio":
+		return fmt.Sprintf("%.2f", float64(w)/float64(h))
+	case "color-space":
+		return colorSpace(config.ColorModel)
+	default:
+		return fmt.Sprintf("%dx%d", w, h)
+	}
+}
+
+// Get name of color space of decoder color model
+func colorSpace(model color.Model) string {
+	switch model {
+	case color.GrayModel, color.Gray16Model:
+		return "gray"
+	case color.CMYKModel:
+		return "cmyk"
+	}
+	if _, ok := model.(color.Palette); ok {
+		return "indexed"
+	}
+	return "rgb"
+}
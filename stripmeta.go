@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+)
+
+// Jpeg segments kept when stripping metadata: JFIF header,
+// ICC profile and Adobe color transform needed for decoding
+var keptJpegMarkers = map[byte]bool{0xE0: true, 0xE2: true, 0xEE: true}
+
+// Png chunks dropped when stripping metadata
+var metadataPngChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+// Remove EXIF, XMP and text metadata from jpeg or png data,
+// other formats and malformed data are returned unchanged
+func stripMetadata(data []byte, format string) []byte {
+	switch format {
+	case "jpeg":
+		return stripJpegMetadata(data)
+	case "png":
+		return stripPngMetadata(data)
+	}
+	return data
+}
+
+// Drop application segments other than kept ones and comments
+// preceding image data of jpeg, e.g. EXIF and XMP in APP1
+func stripJpegMetadata(data []byte) []byte {
+	var result bytes.Buffer
+	pos := 2
+	walkJpegSegments(data, func(marker byte, segment []byte) {
+		length := 4 + len(segment)
+		metadata := marker == 0xFE || (marker >= 0xE0 && marker <= 0xEF && !keptJpegMarkers[marker])
+		if !metadata {
+			result.Write(data[pos : pos+length])
+		}
+		pos += length
+	})
+	if pos == 2 {
+		return data
+	}
+	return append(append([]byte{0xFF, 0xD8}, result.Bytes()...), data[pos:]...)
+}
+
+// Drop text, EXIF and timestamp chunks of png
+func stripPngMetadata(data []byte) []byte {
+	var result bytes.Buffer
+	result.WriteString(pngSignature)
+	walkPngChunks(data, func(chunkType string, chunk []byte) bool {
+		if !metadataPngChunks[chunkType] {
+			writePngChunk(&result, chunkType, chunk)
+		}
+		return true
+	})
+	if result.Len() == len(pngSignature) {
+		return data
+	}
+	return result.Bytes()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	summaryMargin    = 10
+	summaryGap       = 4
+	summaryLabelSize = 8
+)
+
+// Append index pages with thumbnails of all image pages in
+// -summary-cols columns, each labeled with its page number
+func addSummaryPages(document *gofpdf.Fpdf, font textFont, opts *Options, pages pageLog) error {
+	var numbers []int
+	for page, recs := range pages {
+		if len(recs) > 0 {
+			numbers = append(numbers, page)
+		}
+	}
+	sort.Ints(numbers)
+	template := opts.Template
+	cellW := (template.Wd - 2*summaryMargin) / float64(opts.SummaryCols)
+	thumbW := cellW - summaryGap
+	document.SetFont(font.family, "", summaryLabelSize)
+	_, labelH := document.GetFontSize()
+	// single row must fit on page
+	thumbH := math.Min(opts.SummaryThumbHeight, template.Ht-2*summaryMargin-labelH-summaryGap)
+	rowH := thumbH + labelH + summaryGap
+	rows := int((template.Ht - 2*summaryMargin) / rowH)
+	if rows < 1 {
+		rows = 1
+	}
+	// thumbnails are stored at twice screen resolution
+	maxW, maxH := pixelsForSize(thumbW, 2*screenDpi), pixelsForSize(thumbH, 2*screenDpi)
+	for i, page := range numbers {
+		if i%(rows*opts.SummaryCols) == 0 {
+			document.AddPageFormat("P", template)
+			document.SetFont(font.family, "", summaryLabelSize)
+			setTextColor(document, opts, nil, 0, 0, template.Wd, template.Ht)
+		}
+		rec := pages[page][0]
+		img := rec.decoded
+		if img == nil {
+			img = decodeFrame(rec.imagepath, rec.frame)
+		}
+		img = rotateImage(img, rec.degrees)
+		bounds := img.Bounds()
+		_, _, fitW, fitH := letterboxRect(float64(maxW), float64(maxH), float64(bounds.Dx()), float64(bounds.Dy()))
+		img = scaleImage(img, int(fitW), int(fitH))
+		name := fmt.Sprintf("summary:%s#%d:%d", rec.imagepath, rec.frame, page)
+		registerImage(document, name, img, opts.PngCompression)
+		col, row := i%opts.SummaryCols, i/opts.SummaryCols%rows
+		cellX := summaryMargin + float64(col)*cellW
+		cellY := summaryMargin + float64(row)*rowH
+		x, y, w, h := letterboxRect(thumbW, thumbH, fitW, fitH)
+		document.ImageOptions(name, cellX+summaryGap/2+x, cellY+y, w, h, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		document.SetXY(cellX, cellY+thumbH)
+		document.CellFormat(cellW, labelH, strconv.Itoa(page), "", 0, "CM", false, 0, "")
+		if err := checkPdfState(document, "adding summary of page "+strconv.Itoa(page)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Single directory holding all temporary files of a run. It is
+// created on first use inside parent, system default when empty.
+// With 'keep' files are left in place and their paths printed to stderr
+type tempFiles struct {
+	parent string
+	root   string
+	keep   bool
+}
+
+// Create new subdirectory for temporary files
+func (temp *tempFiles) mkdir(prefix string) (string, error) {
+	if temp.root == "" {
+		if temp.parent != "" {
+			if err := os.MkdirAll(temp.parent, 0755); err != nil {
+				return "", err
+			}
+		}
+		root, err := ioutil.TempDir(temp.parent, "imgdir2pdf")
+		if err != nil {
+			return "", err
+		}
+		temp.root = root
+	}
+	dir, err := ioutil.TempDir(temp.root, prefix)
+	if err == nil && temp.keep {
+		fmt.Fprintln(os.Stderr, "temporary files:", dir)
+	}
+	return dir, err
+}
+
+// Remove all temporary files created so far
+func (temp *tempFiles) cleanup() {
+	if temp.root != "" && !temp.keep {
+		os.RemoveAll(temp.root)
+		temp.root = ""
+	}
+}
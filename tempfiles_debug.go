@@ -0,0 +1,7 @@
+//go:build debugtemp
+// +build debugtemp
+
+package main
+
+// Debug build keeps temporary files and reports their paths
+const debugTemp = true
@@ -0,0 +1,7 @@
+//go:build !debugtemp
+// +build !debugtemp
+
+package main
+
+// Temporary files are removed unless -keep-temp is given
+const debugTemp = false
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Color with 0-255 channels
+type rgb struct {
+	r, g, b int
+}
+
+var (
+	black = rgb{0, 0, 0}
+	white = rgb{255, 255, 255}
+)
+
+// Parse color given as #RRGGBB
+func parseHexColor(value string) (rgb, error) {
+	hex := strings.TrimPrefix(value, "#")
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return rgb{}, fmt.Errorf("invalid color %q, expected #RRGGBB", value)
+	}
+	return rgb{int(n >> 16 & 0xFF), int(n >> 8 & 0xFF), int(n & 0xFF)}, nil
+}
+
+// Image placed on page
+type pageRecord struct {
+	imagepath string
+	frame     int
+	// processed image if it was decoded during preparation
+	decoded image.Image
+	// page area covered by image in mm and its clockwise rotation
+	x, y, w, h float64
+	degrees    int
+}
+
+// Records of images placed on pages of document by page number
+type pageLog map[int][]pageRecord
+
+// Set text color for text occupying area x, y, w, h on current page.
+// With -font-color-auto white or black is chosen depending on
+// average luminance of page content below
+func setTextColor(document *gofpdf.Fpdf, opts *Options, pages pageLog, x, y, w, h float64) {
+	c := opts.TextColor
+	if opts.FontColorAuto {
+		c = black
+		if recs, ok := pages[document.PageNo()]; ok && averageLuminance(recs, x, y, w, h) < 128 {
+			c = white
+		}
+	}
+	document.SetTextColor(c.r, c.g, c.b)
+}
+
+// Compute average luminance of page area x, y, w, h sampled
+// on a grid, points outside of images count as white paper
+func averageLuminance(recs []pageRecord, x, y, w, h float64) float64 {
+	const samples = 8
+	// images are decoded only when a sample point falls on them
+	decoded := make([]image.Image, len(recs))
+	var sum float64
+	for i := 0; i < samples; i++ {
+		for j := 0; j < samples; j++ {
+			sum += pointLuminance(recs, decoded, x+w*(float64(i)+0.5)/samples, y+h*(float64(j)+0.5)/samples)
+		}
+	}
+	return sum / (samples * samples)
+}
+
+// Get luminance of page point from image covering it, white if none does
+func pointLuminance(recs []pageRecord, decoded []image.Image, x, y float64) float64 {
+	for k, rec := range recs {
+		// position within image area after rotation
+		u := (x - rec.x) / rec.w
+		v := (y - rec.y) / rec.h
+		if u < 0 || u >= 1 || v < 0 || v >= 1 {
+			continue
+		}
+		if decoded[k] == nil {
+			decoded[k] = rec.decoded
+			if decoded[k] == nil {
+				decoded[k] = decodeFrame(rec.imagepath, rec.frame)
+			}
+		}
+		bounds := decoded[k].Bounds()
+		s, t := unrotate(u, v, rec.degrees)
+		px := bounds.Min.X + int(s*float64(bounds.Dx()))
+		py := bounds.Min.Y + int(t*float64(bounds.Dy()))
+		return float64(color.GrayModel.Convert(decoded[k].At(px, py)).(color.Gray).Y)
+	}
+	return 255
+}
+
+// Map relative position in image rotated clockwise by degrees
+// back to relative position in source image
+func unrotate(u, v float64, degrees int) (s, t float64) {
+	switch degrees {
+	case 90:
+		return v, 1 - u
+	case 180:
+		return 1 - u, 1 - v
+	case 270:
+		return 1 - v, u
+	}
+	return u, v
+}
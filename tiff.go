@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Check if file is tiff judging by its content
+func isTiff(imagepath string) bool {
+	return imageType(imagepath) == "tiff"
+}
+
+// Get byte order of tiff data from its header
+func tiffByteOrder(data []byte) (binary.ByteOrder, error) {
+	if len(data) < 8 {
+		return nil, errors.New("tiff header is truncated")
+	}
+	switch string(data[:4]) {
+	case "II*\x00":
+		return binary.LittleEndian, nil
+	case "MM\x00*":
+		return binary.BigEndian, nil
+	}
+	return nil, errors.New("not a tiff file")
+}
+
+// Find offsets of all image file directories by following
+// the chain which starts in header, each one describes a frame
+func tiffFrameOffsets(data []byte) ([]uint32, error) {
+	order, err := tiffByteOrder(data)
+	if err != nil {
+		return nil, err
+	}
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	offset := order.Uint32(data[4:8])
+	for offset != 0 && !seen[offset] {
+		if int(offset)+2 > len(data) {
+			return nil, errors.New("tiff directory offset out of range")
+		}
+		entries := int(order.Uint16(data[offset : offset+2]))
+		next := int(offset) + 2 + entries*12
+		if next+4 > len(data) {
+			return nil, errors.New("tiff directory is truncated")
+		}
+		seen[offset] = true
+		offsets = append(offsets, offset)
+		offset = order.Uint32(data[next : next+4])
+	}
+	return offsets, nil
+}
+
+// Count frames in tiff file
+func tiffFrameCount(imagepath string) int {
+	data, err := ioutil.ReadFile(imagepath)
+	if err != nil {
+		panic(err)
+	}
+	offsets, err := tiffFrameOffsets(data)
+	if err != nil {
+		panic(err)
+	}
+	return len(offsets)
+}
+
+// Get reader of tiff data whose first frame is the requested one.
+// All offsets in tiff are absolute, so it is enough to point
+// header to directory of the frame, decoder then reads only it
+func openTiffFrame(imagepath string, frame int) *bytes.Reader {
+	data, err := ioutil.ReadFile(imagepath)
+	if err != nil {
+		panic(err)
+	}
+	offsets, err := tiffFrameOffsets(data)
+	if err != nil {
+		panic(err)
+	}
+	if frame >= len(offsets) {
+		panic("tiff frame out of range: " + imagepath)
+	}
+	order, _ := tiffByteOrder(data)
+	order.PutUint32(data[4:8], offsets[frame])
+	return bytes.NewReader(data)
+}
+
+// Find value of short or long tag in first directory of tiff data
+func tiffTag(data []byte, tag uint16) (uint32, bool) {
+	order, err := tiffByteOrder(data)
+	if err != nil {
+		return 0, false
+	}
+	return tiffDirTag(data, order, int(order.Uint32(data[4:8])), tag)
+}
+
+const (
+	tiffTagWidth       = 256
+	tiffTagHeight      = 257
+	tiffTagPhotometric = 262
+	tiffTagSubIFDs     = 330
+)
+
+// Image file directory holding one resolution level
+type tiffLevel struct {
+	offset uint32
+	width  uint32
+}
+
+// Find entry of tag in directory at offset, returns its type,
+// count and offset of 4-byte value field
+func tiffEntry(data []byte, order binary.ByteOrder, offset int, tag uint16) (kind uint16, count uint32, field int, ok bool) {
+	if offset+2 > len(data) {
+		return 0, 0, 0, false
+	}
+	entries := int(order.Uint16(data[offset : offset+2]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			return 0, 0, 0, false
+		}
+		if order.Uint16(data[entry:entry+2]) == tag {
+			return order.Uint16(data[entry+2 : entry+4]), order.Uint32(data[entry+4 : entry+8]), entry + 8, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// Get value of short or long tag in directory at offset
+func tiffDirTag(data []byte, order binary.ByteOrder, offset int, tag uint16) (uint32, bool) {
+	kind, _, field, ok := tiffEntry(data, order, offset, tag)
+	switch {
+	case !ok:
+		return 0, false
+	case kind == 3: // short
+		return uint32(order.Uint16(data[field : field+2])), true
+	case kind == 4: // long
+		return order.Uint32(data[field : field+4]), true
+	}
+	return 0, false
+}
+
+// Find value of ascii tag in first directory of tiff data,
+// trailing zero bytes are dropped
+func tiffString(data []byte, tag uint16) (string, bool) {
+	order, err := tiffByteOrder(data)
+	if err != nil {
+		return "", false
+	}
+	kind, count, field, ok := tiffEntry(data, order, int(order.Uint32(data[4:8])), tag)
+	if !ok || kind != 2 {
+		return "", false
+	}
+	if count > 4 {
+		field = int(order.Uint32(data[field : field+4]))
+	}
+	if field+int(count) > len(data) {
+		return "", false
+	}
+	return strings.TrimRight(string(data[field:field+int(count)]), "\x00"), true
+}
+
+// Get offsets of directories listed in SubIFDs tag, as used by
+// DNG and pyramidal tiff to store further resolutions of image
+func tiffSubIFDs(data []byte, order binary.ByteOrder, offset int) []uint32 {
+	kind, count, field, ok := tiffEntry(data, order, offset, tiffTagSubIFDs)
+	// long or ifd type
+	if !ok || (kind != 4 && kind != 13) {
+		return nil
+	}
+	if count > 1 {
+		field = int(order.Uint32(data[field : field+4]))
+	}
+	var result []uint32
+	for i := 0; i < int(count) && field+i*4+4 <= len(data); i++ {
+		result = append(result, order.Uint32(data[field+i*4:field+i*4+4]))
+	}
+	return result
+}
+
+// Collect resolution levels stored in tiff data, both chained
+// directories and SubIFDs, ordered from smallest to largest.
+// Raw sensor data of DNG is skipped, since it can not be decoded
+func tiffLevels(data []byte) ([]tiffLevel, error) {
+	order, err := tiffByteOrder(data)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := tiffFrameOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	dirs := append([]uint32(nil), offsets...)
+	for _, offset := range offsets {
+		dirs = append(dirs, tiffSubIFDs(data, order, int(offset))...)
+	}
+	var levels []tiffLevel
+	for _, offset := range dirs {
+		// white is zero, black is zero, rgb and palette
+		if photometric, ok := tiffDirTag(data, order, int(offset), tiffTagPhotometric); ok && photometric > 3 {
+			continue
+		}
+		width, ok := tiffDirTag(data, order, int(offset), tiffTagWidth)
+		if ok {
+			levels = append(levels, tiffLevel{offset: offset, width: width})
+		}
+	}
+	if len(levels) == 0 {
+		return nil, errors.New("tiff contains no decodable image")
+	}
+	sort.SliceStable(levels, func(i, j int) bool { return levels[i].width < levels[j].width })
+	return levels, nil
+}
+
+// Get reader of tiff data whose first directory is resolution level,
+// 0 being the smallest. Levels beyond available ones and -1 select the
+// largest
+func openTiffLevel(imagepath string, level int) *bytes.Reader {
+	data, err := ioutil.ReadFile(imagepath)
+	if err != nil {
+		panic(err)
+	}
+	levels, err := tiffLevels(data)
+	if err != nil {
+		panic(fmt.Errorf("%s: %v", imagepath, err))
+	}
+	if level < 0 || level >= len(levels) {
+		level = len(levels) - 1
+	}
+	order, _ := tiffByteOrder(data)
+	order.PutUint32(data[4:8], levels[level].offset)
+	return bytes.NewReader(data)
+}
+
+// Parse value of -image-resolution-level, full is returned as -1
+func parseResolutionLevel(value string) (int, error) {
+	if value == "full" {
+		return -1, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 {
+		return 0, fmt.Errorf("invalid image-resolution-level %q, expected 0, 1, 2, ... or full", value)
+	}
+	return level, nil
+}
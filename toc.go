@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"path/filepath"
+)
+
+const (
+	tocTitleSize = 16
+	tocEntrySize = 11
+	// width of page number column in mm
+	tocNumberWidth = 20
+)
+
+// Table of contents of chapter listing its images. Page numbers
+// are unknown while it is rendered, so aliases are written
+// and replaced by gofpdf once images are added
+type chapterToc struct {
+	document *gofpdf.Fpdf
+	aliases  []string
+	// number of last page of table, first image follows it
+	start int
+}
+
+// Add pages listing images of chapter with page numbers
+// relative to chapter start
+func addChapterToc(document *gofpdf.Fpdf, opts *Options, font textFont, index int, ch chapter) *chapterToc {
+	template := opts.Template
+	toc := &chapterToc{document: document}
+	newPage := func() {
+		document.AddPageFormat("P", template)
+		setTextColor(document, opts, nil, 0, 0, template.Wd, template.Ht)
+		document.SetXY(chapterTitleMargin, chapterTitleMargin)
+	}
+	newPage()
+	document.SetFont(font.family, font.style, tocTitleSize)
+	_, lineH := document.GetFontSize()
+	document.CellFormat(template.Wd-2*chapterTitleMargin, 2*lineH, font.encode(document, ch.name), "", 1, "L", false, 0, "")
+	document.SetFont(font.family, "", tocEntrySize)
+	_, lineH = document.GetFontSize()
+	lineH *= 1.5
+	nameW := template.Wd - 2*chapterTitleMargin - tocNumberWidth
+	for i, elem := range ch.paths {
+		if document.GetY()+lineH > template.Ht-chapterTitleMargin {
+			newPage()
+		}
+		alias := fmt.Sprintf("{toc:%d:%d}", index, i)
+		toc.aliases = append(toc.aliases, alias)
+		document.SetX(chapterTitleMargin)
+		document.CellFormat(nameW, lineH, font.encode(document, filepath.Base(elem)), "", 0, "L", false, 0, "")
+		// alias is replaced after layout, so number is left aligned
+		document.CellFormat(tocNumberWidth, lineH, alias, "", 1, "L", false, 0, "")
+	}
+	toc.start = document.PageNo()
+	return toc
+}
+
+// Register page number of i-th image given number of pages before
+// it was added, skipped images are marked by dash
+func (toc *chapterToc) resolve(i, pagesBefore int) {
+	number := "-"
+	if toc.document.PageNo() > pagesBefore {
+		number = fmt.Sprint(pagesBefore + 1 - toc.start)
+	}
+	toc.document.RegisterAlias(toc.aliases[i], number)
+}
+
+// Mark images from i on as absent, when document is finished
+// before whole chapter is added
+func (toc *chapterToc) abandon(i int) {
+	for ; i < len(toc.aliases); i++ {
+		toc.document.RegisterAlias(toc.aliases[i], "-")
+	}
+}
+
+// Get texts rendered into tables of contents
+func tocTexts(chapters []chapter) []string {
+	texts := []string{"0123456789-"}
+	for _, ch := range chapters {
+		if ch.name == "" {
+			continue
+		}
+		texts = append(texts, ch.name)
+		for _, elem := range ch.paths {
+			texts = append(texts, filepath.Base(elem))
+		}
+	}
+	return texts
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Value of -sort ordering digits of any script numerically
+const sortNaturalUnicode = "natural-unicode"
+
+// Get numeric value of decimal digit of any script. Unicode
+// allocates decimal digits in contiguous runs starting with zero
+func digitValue(r rune) int {
+	for _, rng := range unicode.Nd.R16 {
+		if r >= rune(rng.Lo) && r <= rune(rng.Hi) {
+			return int(r-rune(rng.Lo)) % 10
+		}
+	}
+	for _, rng := range unicode.Nd.R32 {
+		if r >= rune(rng.Lo) && r <= rune(rng.Hi) {
+			return int(r-rune(rng.Lo)) % 10
+		}
+	}
+	return 0
+}
+
+// Sort key ordering every run of digits in filename by its value,
+// digits of all scripts count, e.g. Arabic-Indic ١٠ follows ٩
+func unicodeSortName(filename string) string {
+	ext := filepath.Ext(filename)
+	return unicodeNumberKey(filename[:len(filename)-len(ext)]) + ext
+}
+
+// Replace runs of digits in name by zero byte followed by their
+// value as uint64 bytes, so that numbers compare by value
+func unicodeNumberKey(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i := 0; i < len(runes); {
+		if !unicode.IsDigit(runes[i]) {
+			sb.WriteRune(runes[i])
+			i++
+			continue
+		}
+		var value uint64
+		for ; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			digit := uint64(digitValue(runes[i]))
+			if value > (math.MaxUint64-digit)/10 {
+				value = math.MaxUint64
+				continue
+			}
+			value = value*10 + digit
+		}
+		b64 := make([]byte, 64/8)
+		binary.BigEndian.PutUint64(b64, value)
+		sb.WriteByte(0)
+		sb.Write(b64)
+	}
+	return sb.String()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// Extract frames of video at fps into new temporary directory by
+// running ffmpeg
+func extractVideoFrames(video string, fps float64, temp *tempFiles) (string, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return "", fmt.Errorf("-from-video requires ffmpeg in PATH: %v", err)
+	}
+	workDir, err := temp.mkdir("frames")
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("ffmpeg", "-loglevel", "error", "-i", video,
+		"-vf", "fps="+strconv.FormatFloat(fps, 'f', -1, 64),
+		filepath.Join(workDir, "%04d.png"))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed to extract frames of %s: %v", video, err)
+	}
+	return workDir, nil
+}
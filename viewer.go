@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Page layouts accepted by -page-layout as understood by gofpdf
+var pageLayouts = map[string]string{
+	"single":     "single",
+	"two-page":   "two",
+	"continuous": "continuous",
+}
+
+// Parse -initial-zoom value, zero stands for fitting whole page
+func parseZoom(zoom string) (float64, error) {
+	if zoom == "fit" {
+		return 0, nil
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(zoom, "%"), 64)
+	if err != nil || percent <= 0 {
+		return 0, fmt.Errorf("initial-zoom must be fit or positive percentage, got %q", zoom)
+	}
+	return percent, nil
+}
+
+// Set how document opens using gofpdf display mode,
+// which supports only fixed zoom modes
+func setupViewer(document *gofpdf.Fpdf, opts *Options) {
+	zoom := "fullpage"
+	if opts.ZoomPercent > 0 {
+		// written by viewerPreferences instead
+		zoom = "default"
+	}
+	document.SetDisplayMode(zoom, pageLayouts[opts.PageLayout])
+}
+
+var (
+	catalogPattern   = []byte("/Type /Catalog\n")
+	xrefEntryPattern = regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`)
+	startxrefPattern = regexp.MustCompile(`startxref\n(\d+)\n`)
+)
+
+// Get zoom and viewer preferences entries of document catalog
+func viewerPreferences(opts *Options) []byte {
+	var entries bytes.Buffer
+	if opts.ZoomPercent > 0 {
+		// gofpdf always writes first page as object 3
+		fmt.Fprintf(&entries, "/OpenAction [3 0 R /XYZ null null %.2f]\n", opts.ZoomPercent/100)
+	}
+	if opts.HideToolbar || opts.HideMenubar {
+		fmt.Fprintf(&entries, "/ViewerPreferences << /HideToolbar %t /HideMenubar %t >>\n",
+			opts.HideToolbar, opts.HideMenubar)
+	}
+	return entries.Bytes()
+}
+
+// Insert entries into document catalog of pdf produced by gofpdf,
+// offsets of objects following the insertion are shifted in
+// cross-reference table
+func insertCatalogEntries(data, entries []byte) ([]byte, error) {
+	pos := bytes.LastIndex(data, catalogPattern)
+	if pos < 0 {
+		return nil, errors.New("document catalog not found")
+	}
+	pos += len(catalogPattern)
+	shift := len(entries)
+	result := make([]byte, 0, len(data)+shift)
+	result = append(result, data[:pos]...)
+	result = append(result, entries...)
+	rest := xrefEntryPattern.ReplaceAllFunc(data[pos:], func(entry []byte) []byte {
+		return []byte(fmt.Sprintf("%010d 00000 n ", shiftOffset(entry[:10], pos, shift)))
+	})
+	rest = startxrefPattern.ReplaceAllFunc(rest, func(match []byte) []byte {
+		offset := startxrefPattern.FindSubmatch(match)[1]
+		return []byte(fmt.Sprintf("startxref\n%d\n", shiftOffset(offset, pos, shift)))
+	})
+	return append(result, rest...), nil
+}
+
+// Move offset by shift when it points past insertion position
+func shiftOffset(digits []byte, pos, shift int) int {
+	offset, _ := strconv.Atoi(string(digits))
+	if offset >= pos {
+		offset += shift
+	}
+	return offset
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Receives every warning, returned error aborts conversion
+type WarnHandler func(message string) error
+
+// Print warning to stderr and go on, stdout may carry pdf
+func printWarning(message string) error {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+	return nil
+}
+
+// Turn warning into error, used by -strict-mode
+func failOnWarning(message string) error {
+	return fmt.Errorf("strict mode: %s", message)
+}
+
+// Pass formatted warning to handler of options
+func (opts *Options) warn(format string, args ...interface{}) error {
+	return opts.Warn(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Parse -sort value, returns name of extended attribute
+// to sort by, empty for sorting by name
+func parseSort(key string) (string, error) {
+	if key == "name" || key == sortNaturalUnicode {
+		return "", nil
+	}
+	if attr := strings.TrimPrefix(key, "xattr:"); attr != key && attr != "" {
+		if !xattrSupported {
+			return "", fmt.Errorf("sort by xattr is not supported on this platform")
+		}
+		return attr, nil
+	}
+	return "", fmt.Errorf("unknown sort %q, expected name, natural-unicode or xattr:ATTR_NAME", key)
+}
+
+// Sort key by value of extended attribute of file in dirpath. Files
+// with attribute go first, others follow ordered by fallback key
+func xattrSortName(dirpath, filename, attr string, fallback func(string) string) string {
+	if value, ok := getXattr(filepath.Join(dirpath, filename), attr); ok {
+		return "\x00" + value
+	}
+	return "\x01" + fallback(filename)
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+// Extended attributes are not available
+const xattrSupported = false
+
+// Read value of extended attribute, never present on this platform
+func getXattr(path, attr string) (string, bool) {
+	return "", false
+}
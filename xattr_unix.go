@@ -0,0 +1,23 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// Extended attributes are available
+const xattrSupported = true
+
+// Read value of extended attribute, ok is false when it is absent
+func getXattr(path, attr string) (string, bool) {
+	size, err := unix.Getxattr(path, attr, nil)
+	if err != nil || size < 0 {
+		return "", false
+	}
+	buf := make([]byte, size)
+	size, err = unix.Getxattr(path, attr, buf)
+	if err != nil {
+		return "", false
+	}
+	return string(buf[:size]), true
+}